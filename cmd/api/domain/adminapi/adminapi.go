@@ -0,0 +1,61 @@
+package adminapi
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"ticket-booking-backend/tool/rabbitmq"
+
+	"github.com/gin-gonic/gin"
+)
+
+var defaultDeadLetterLimit = 20
+
+func GetDeadLettersHandler(mq *rabbitmq.RabbitMQ) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		limit, err := getLimit(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		deadLetters, err := mq.PeekDeadLetters(ctx.Param("action"), limit)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"dead_letters": deadLetters})
+	}
+}
+
+func ReplayDeadLettersHandler(mq *rabbitmq.RabbitMQ) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		limit, err := getLimit(ctx)
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		replayed, err := mq.ReplayDeadLetters(ctx.Param("action"), limit)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "replayed": replayed})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": "Dead letters replayed", "replayed": replayed})
+	}
+}
+
+func getLimit(ctx *gin.Context) (int, error) {
+	limitStr := ctx.Query("limit")
+	if limitStr == "" {
+		return defaultDeadLetterLimit, nil
+	}
+
+	limit, err := strconv.Atoi(limitStr)
+	if err != nil || limit < 1 || limit > 1000 {
+		return 0, fmt.Errorf("limit must be between 1 and 1000")
+	}
+	return limit, nil
+}
@@ -0,0 +1,96 @@
+package paymentapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"ticket-booking-backend/domain/booking"
+	"ticket-booking-backend/domain/event"
+	"ticket-booking-backend/domain/payment"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/domain/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PayHandler(paymentService *payment.PaymentService, eventService *event.EventService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// verify event exist
+		eventIDStr := ctx.Param("event_id")
+		eventID, err := strconv.Atoi(eventIDStr) // Convert event_id to int
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+		existEvent, err := eventService.Exist(eventID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check event existence: " + err.Error()})
+			return
+		}
+		if !existEvent {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "event does not exist"})
+			return
+		}
+
+		holdID := ctx.Param("hold_id")
+		if _, err := ticket.ParseHoldID(holdID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := paymentService.RequestPayment(ctx, eventID, holdID); err != nil {
+			switch {
+			case errors.Is(err, user.ErrNotLoggedIn):
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			case errors.Is(err, ticket.ErrHoldNotFound):
+				ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			default:
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "Payment request received"})
+	}
+}
+
+// CheckoutHandler pays and books every hold of the session for the event
+func CheckoutHandler(paymentService *payment.PaymentService, eventService *event.EventService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// verify event exist
+		eventIDStr := ctx.Param("event_id")
+		eventID, err := strconv.Atoi(eventIDStr) // Convert event_id to int
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+		existEvent, err := eventService.Exist(eventID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check event existence: " + err.Error()})
+			return
+		}
+		if !existEvent {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "event does not exist"})
+			return
+		}
+
+		bookedHolds, err := paymentService.Checkout(ctx, eventID)
+		if err != nil {
+			switch {
+			case errors.Is(err, user.ErrNotLoggedIn):
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			case errors.Is(err, ticket.ErrHoldNotFound):
+				ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			case errors.Is(err, payment.ErrPaymentDeclined):
+				ctx.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error()})
+			case errors.Is(err, booking.ErrSeatAlreadyBooked):
+				ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			default:
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			}
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"bookings": bookedHolds})
+	}
+}
@@ -1,6 +1,7 @@
 package ticketapi
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -15,11 +16,14 @@ import (
 
 // use form instead of json
 type TicketQuery struct {
-	Number    int `form:"number" validate:"required,min=1,max=6"`
-	LowPrice  int `form:"low_price" validate:"required,min=0"`
-	HighPrice int `form:"high_price" validate:"required,min=0,gtfield=LowPrice"`
-	Page      int `form:"page" validate:"required,min=1"`
-	PageSize  int `form:"page_size" validate:"required,min=1,max=100"`
+	Number    int    `form:"number" validate:"required,min=1,max=6"`
+	LowPrice  int    `form:"low_price" validate:"required,min=0"`
+	HighPrice int    `form:"high_price" validate:"required,min=0,gtfield=LowPrice"`
+	Page      int    `form:"page" validate:"omitempty,min=1"` // ignored when cursor is set
+	PageSize  int    `form:"page_size" validate:"required,min=1,max=100"`
+	Mode      string `form:"mode" validate:"omitempty,oneof=best seats"` // best (default): longest row per price, seats: every run of seats
+	Sort      string `form:"sort" validate:"omitempty,oneof=price_asc price_desc best_view length"`
+	Cursor    string `form:"cursor"` // next_cursor of the previous page
 }
 
 func GetTicketsHandler(ticketService *ticket.TicketService,
@@ -63,10 +67,33 @@ func GetTicketsHandler(ticketService *ticket.TicketService,
 
 		log.Println("verify query")
 
-		tickets, err := ticketService.GetTickets(ctx, eventID, query.Number, query.LowPrice, query.HighPrice, query.Page, query.PageSize, venueService, eventService)
+		if query.Page == 0 {
+			query.Page = 1
+		}
+		search := ticket.TicketSearch{
+			EventID:   eventID,
+			Number:    query.Number,
+			LowPrice:  query.LowPrice,
+			HighPrice: query.HighPrice,
+			Sort:      query.Sort,
+			Page:      query.Page,
+			PageSize:  query.PageSize,
+			Cursor:    query.Cursor,
+		}
+
+		var tickets interface{}
+		if query.Mode == "seats" {
+			tickets, err = ticketService.GetTicketRuns(ctx, search, venueService)
+		} else {
+			tickets, err = ticketService.GetTickets(ctx, search, venueService, eventService)
+		}
 		if err != nil {
+			if errors.Is(err, ticket.ErrInvalidCursor) {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tickets"})
-			log.Fatal(err)
+			log.Println(err)
 			return
 		}
 		log.Printf("Retrieved Tickets: %+v\n", tickets)
@@ -106,11 +133,122 @@ func ReserveHandler(ticketService *ticket.TicketService, eventService *event.Eve
 			return
 		}
 
-		if err := ticketService.ReserveTicket(ctx, eventID, reqDTO.SectionID, reqDTO.RowID, reqDTO.Price, reqDTO.Length); err != nil {
+		var requestID string
+		if len(reqDTO.SeatIDs) > 0 {
+			requestID, err = ticketService.ReserveSeats(ctx, eventID, reqDTO.Price, reqDTO.SeatIDs)
+		} else {
+			requestID, err = ticketService.ReserveTicket(ctx, eventID, reqDTO.SectionID, reqDTO.RowID, reqDTO.Price, reqDTO.Length)
+		}
+		if err != nil {
+			if errors.Is(err, ticket.ErrSeatNotOnSale) || errors.Is(err, ticket.ErrSeatPriceMismatch) {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusAccepted, gin.H{"message": "Reservation request received", "request_id": requestID})
+	}
+}
+
+func CancelHoldHandler(ticketService *ticket.TicketService, eventService *event.EventService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// verify event exist
+		eventIDStr := ctx.Param("event_id")
+		eventID, err := strconv.Atoi(eventIDStr) // Convert event_id to int
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+		existEvent, err := eventService.Exist(eventID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check event existence: " + err.Error()})
+			return
+		}
+		if !existEvent {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "event does not exist"})
+			return
+		}
+
+		holdID := ctx.Param("hold_id")
+		if _, err := ticket.ParseHoldID(holdID); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sessionID := ctx.GetString("session_id")
+		if err := ticketService.CancelHold(ctx.Request.Context(), sessionID, eventID, holdID); err != nil {
+			if errors.Is(err, ticket.ErrHoldNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled", "hold_ids": []string{holdID}})
+	}
+}
+
+func CancelAllHoldsHandler(ticketService *ticket.TicketService, eventService *event.EventService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// verify event exist
+		eventIDStr := ctx.Param("event_id")
+		eventID, err := strconv.Atoi(eventIDStr) // Convert event_id to int
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+		existEvent, err := eventService.Exist(eventID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check event existence: " + err.Error()})
+			return
+		}
+		if !existEvent {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "event does not exist"})
+			return
+		}
+
+		sessionID := ctx.GetString("session_id")
+		cancelled, err := ticketService.CancelHolds(ctx.Request.Context(), sessionID, eventID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "hold_ids": cancelled})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"message": "Reservations cancelled", "hold_ids": cancelled})
+	}
+}
+
+func ListHoldsHandler(ticketService *ticket.TicketService, venueService *venue.VenueService, eventService *event.EventService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sessionID := ctx.GetString("session_id")
+
+		holds, err := ticketService.ListHolds(ctx.Request.Context(), sessionID, venueService, eventService)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusOK, gin.H{"reservations": holds})
+	}
+}
+
+func GetReservationRequestHandler(ticketService *ticket.TicketService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		sessionID := ctx.GetString("session_id")
+
+		request, err := ticketService.GetReservationRequest(ctx.Request.Context(), sessionID, ctx.Param("id"))
+		if err != nil {
+			if errors.Is(err, ticket.ErrRequestNotFound) {
+				ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				return
+			}
 			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 			return
 		}
 
-		ctx.JSON(http.StatusOK, gin.H{"message": "Reservation request received"})
+		ctx.JSON(http.StatusOK, request)
 	}
 }
@@ -1,7 +1,11 @@
 package userapi
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"ticket-booking-backend/cmd/api/session"
+	"ticket-booking-backend/domain/ticket"
 	"ticket-booking-backend/domain/user"
 	"ticket-booking-backend/dto"
 
@@ -31,3 +35,58 @@ func CreateUserHandler(service *user.UserService, validator *validator.Validate)
 		ctx.JSON(http.StatusOK, gin.H{"message": "User registered successfully"})
 	}
 }
+
+// LoginHandler logs the session in, paying and checking out need a logged in session.
+// The session ID is rotated on login: a session ID planted before logging in is never authenticated.
+// The holds of the old session move to the new one.
+func LoginHandler(service *user.UserService, sessionManager *session.SessionManager,
+	ticketService *ticket.TicketService, validator *validator.Validate) gin.HandlerFunc {
+
+	return func(ctx *gin.Context) {
+		var postLogin dto.PostLogin
+		if err := ctx.ShouldBindJSON(&postLogin); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := validator.Struct(postLogin); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		sessionID, exists := ctx.Get("session_id")
+		if !exists {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "session ID not found in context"})
+			return
+		}
+		oldSessionID := sessionID.(string)
+
+		newSessionID := session.NewID()
+		userID, err := service.Login(postLogin.Email, postLogin.Password, newSessionID)
+		if err != nil {
+			if errors.Is(err, user.ErrInvalidCredentials) {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		if err := sessionManager.Create(ctx.Request.Context(), newSessionID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+			return
+		}
+		if _, err := ticketService.MoveHolds(ctx.Request.Context(), oldSessionID, newSessionID); err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := sessionManager.Delete(ctx.Request.Context(), oldSessionID); err != nil {
+			log.Printf("failed to delete session replaced by login: %v", err)
+		}
+
+		session.SetCookie(ctx, newSessionID)
+		ctx.Set("session_id", newSessionID)
+
+		ctx.JSON(http.StatusOK, gin.H{"message": "Logged in successfully", "user_id": userID})
+	}
+}
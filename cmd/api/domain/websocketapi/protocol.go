@@ -0,0 +1,128 @@
+package websocketapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/dto"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+var commandTimeout = 5 * time.Second
+
+// handleMessage runs a client command and returns the reply envelope
+func handleMessage(cm *websocket.ConnectionManager, client *websocket.Client,
+	ticketService *ticket.TicketService, validator *validator.Validate, data []byte) []byte {
+	var envelope dto.Envelope
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return errorReply("", dto.ErrorBadRequest, "invalid envelope")
+	}
+
+	// A missing version is read as the current one
+	if envelope.Version != 0 && envelope.Version != dto.WSProtocolVersion {
+		return errorReply(envelope.ID, dto.ErrorUnsupportedVersion, "unsupported protocol version")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	switch envelope.Type {
+	case dto.TypePing:
+		return reply(dto.TypePong, envelope.ID, nil)
+
+	case dto.TypeSubscribe:
+		var payload dto.SubscribePayload
+		if err := decodePayload(validator, envelope.Payload, &payload); err != nil {
+			return errorReply(envelope.ID, dto.ErrorBadRequest, err.Error())
+		}
+		cm.Subscribe(client, payload.EventID, payload.Sections)
+		if payload.LastSeq == 0 {
+			return reply(dto.TypeAck, envelope.ID, payload)
+		}
+
+		// Ack first, then the missed messages
+		if err := client.Send(reply(dto.TypeAck, envelope.ID, payload)); err != nil {
+			return nil
+		}
+		seq, resync, err := cm.Replay(ctx, client, payload.EventID, payload.LastSeq)
+		if err != nil {
+			log.Printf("failed to replay event %d: %v", payload.EventID, err)
+			resync = true
+		}
+		if resync {
+			return reply(dto.TypeResyncRequired, "", dto.ResyncPayload{EventID: payload.EventID, Seq: seq})
+		}
+		return nil
+
+	case dto.TypeUnsubscribe:
+		var payload dto.UnsubscribePayload
+		if err := decodePayload(validator, envelope.Payload, &payload); err != nil {
+			return errorReply(envelope.ID, dto.ErrorBadRequest, err.Error())
+		}
+		cm.Unsubscribe(client, payload.EventID)
+		return reply(dto.TypeAck, envelope.ID, payload)
+
+	case dto.TypeHoldRefresh:
+		var payload dto.HoldPayload
+		if err := decodePayload(validator, envelope.Payload, &payload); err != nil {
+			return errorReply(envelope.ID, dto.ErrorBadRequest, err.Error())
+		}
+		deadline, err := ticketService.RefreshHold(ctx, client.SessionID(), payload.EventID, payload.HoldID)
+		if err != nil {
+			return holdErrorReply(envelope.ID, err)
+		}
+		return reply(dto.TypeAck, envelope.ID, dto.HoldRefreshedPayload{
+			HoldID:    payload.HoldID,
+			ExpiresIn: int(time.Until(deadline).Seconds()),
+		})
+
+	case dto.TypeCancel:
+		var payload dto.HoldPayload
+		if err := decodePayload(validator, envelope.Payload, &payload); err != nil {
+			return errorReply(envelope.ID, dto.ErrorBadRequest, err.Error())
+		}
+		if err := ticketService.CancelHold(ctx, client.SessionID(), payload.EventID, payload.HoldID); err != nil {
+			return holdErrorReply(envelope.ID, err)
+		}
+		return reply(dto.TypeAck, envelope.ID, payload)
+
+	default:
+		return errorReply(envelope.ID, dto.ErrorUnknownType, "unknown message type: "+envelope.Type)
+	}
+}
+
+func decodePayload(validator *validator.Validate, payload json.RawMessage, v interface{}) error {
+	if len(payload) == 0 {
+		return errors.New("payload is required")
+	}
+	if err := json.Unmarshal(payload, v); err != nil {
+		return errors.New("invalid payload")
+	}
+	return validator.Struct(v)
+}
+
+func holdErrorReply(id string, err error) []byte {
+	if errors.Is(err, ticket.ErrHoldNotFound) {
+		return errorReply(id, dto.ErrorHoldNotFound, err.Error())
+	}
+	log.Printf("failed to run hold command: %v", err)
+	return errorReply(id, dto.ErrorInternal, "failed to process command")
+}
+
+func errorReply(id, code, message string) []byte {
+	return reply(dto.TypeError, id, dto.ErrorPayload{Code: code, Message: message})
+}
+
+func reply(msgType, id string, payload interface{}) []byte {
+	data, err := dto.NewEnvelope(msgType, id, payload)
+	if err != nil {
+		log.Printf("failed to marshal reply: %v", err)
+		return nil
+	}
+	return data
+}
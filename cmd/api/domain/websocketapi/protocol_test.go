@@ -0,0 +1,76 @@
+package websocketapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/dto"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-playground/validator/v10"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+func TestHandleMessage(t *testing.T) {
+	tests := []struct {
+		name      string
+		message   string
+		wantType  string
+		wantID    string
+		wantError string // error code, when wantType is dto.TypeError
+	}{
+		{name: "ping", message: `{"v":1,"type":"ping","id":"1"}`, wantType: dto.TypePong, wantID: "1"},
+		{name: "missing version is the current one", message: `{"type":"ping","id":"1"}`, wantType: dto.TypePong, wantID: "1"},
+		{name: "unsupported version", message: `{"v":2,"type":"ping","id":"1"}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorUnsupportedVersion},
+		{name: "invalid envelope", message: `{"v":1,`, wantType: dto.TypeError, wantError: dto.ErrorBadRequest},
+		{name: "unknown type", message: `{"v":1,"type":"book","id":"1"}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorUnknownType},
+		{name: "subscribe", message: `{"v":1,"type":"subscribe","id":"1","payload":{"event_id":1}}`, wantType: dto.TypeAck, wantID: "1"},
+		{name: "subscribe without payload", message: `{"v":1,"type":"subscribe","id":"1"}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorBadRequest},
+		{name: "subscribe with invalid payload", message: `{"v":1,"type":"subscribe","id":"1","payload":{"event_id":0}}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorBadRequest},
+		{name: "unsubscribe without payload", message: `{"v":1,"type":"unsubscribe","id":"1"}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorBadRequest},
+		{name: "hold refresh without payload", message: `{"v":1,"type":"hold_refresh","id":"1"}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorBadRequest},
+		{name: "hold refresh of an unknown hold", message: `{"v":1,"type":"hold_refresh","id":"1","payload":{"event_id":1,"hold_id":"1:2:3:1:1"}}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorHoldNotFound},
+		{name: "cancel without payload", message: `{"v":1,"type":"cancel","id":"1"}`, wantType: dto.TypeError, wantID: "1", wantError: dto.ErrorBadRequest},
+	}
+
+	t.Setenv("BROADCAST_WINDOW_MS", "0")
+	redisClient := redislib.NewClient(&redislib.Options{Addr: miniredis.RunT(t).Addr()})
+	defer redisClient.Close()
+
+	cm := websocket.NewConnectionManager(redisClient, nil)
+	ticketService := ticket.NewTicketService(redisClient, nil, nil, nil, cm)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := cm.AddStream("session-1", httptest.NewRecorder())
+			if err != nil {
+				t.Fatalf("AddStream() error = %v", err)
+			}
+			defer client.Close()
+
+			var envelope dto.Envelope
+			if err := json.Unmarshal(handleMessage(cm, client, ticketService, validator.New(), []byte(tt.message)), &envelope); err != nil {
+				t.Fatalf("failed to decode reply: %v", err)
+			}
+			if envelope.Type != tt.wantType || envelope.ID != tt.wantID {
+				t.Errorf("reply = %s %q, want %s %q", envelope.Type, envelope.ID, tt.wantType, tt.wantID)
+			}
+			if envelope.Version != dto.WSProtocolVersion {
+				t.Errorf("reply version = %d, want %d", envelope.Version, dto.WSProtocolVersion)
+			}
+
+			if tt.wantType != dto.TypeError {
+				return
+			}
+			var payload dto.ErrorPayload
+			if err := json.Unmarshal(envelope.Payload, &payload); err != nil {
+				t.Fatalf("failed to decode error payload: %v", err)
+			}
+			if payload.Code != tt.wantError {
+				t.Errorf("error code = %s, want %s (%s)", payload.Code, tt.wantError, payload.Message)
+			}
+		})
+	}
+}
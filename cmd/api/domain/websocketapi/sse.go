@@ -0,0 +1,94 @@
+package websocketapi
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/domain/event"
+	"ticket-booking-backend/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StreamHandler is the Server-Sent Events fallback of /ws, for networks that break WebSockets.
+// It streams the availability of the event and the notifications of the session as envelopes.
+// Query: sections, comma separated section IDs (default every section), last_seq to replay missed messages.
+func StreamHandler(cm *websocket.ConnectionManager, eventService *event.EventService) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		eventID, err := strconv.Atoi(ctx.Param("event_id"))
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid event ID"})
+			return
+		}
+		existEvent, err := eventService.Exist(eventID)
+		if err != nil {
+			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check event existence: " + err.Error()})
+			return
+		}
+		if !existEvent {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": "event does not exist"})
+			return
+		}
+
+		sessionID := ctx.GetString("session_id")
+		if sessionID == "" || ctx.GetBool("session_new") {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Session is required"})
+			return
+		}
+
+		var sections []int
+		if sectionsStr := ctx.Query("sections"); sectionsStr != "" {
+			for _, sectionStr := range strings.Split(sectionsStr, ",") {
+				sectionID, err := strconv.Atoi(strings.TrimSpace(sectionStr))
+				if err != nil {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid section ID"})
+					return
+				}
+				sections = append(sections, sectionID)
+			}
+		}
+
+		var lastSeq int64
+		if lastSeqStr := ctx.Query("last_seq"); lastSeqStr != "" {
+			lastSeq, err = strconv.ParseInt(lastSeqStr, 10, 64)
+			if err != nil || lastSeq < 0 {
+				ctx.JSON(http.StatusBadRequest, gin.H{"error": "invalid last_seq"})
+				return
+			}
+		}
+
+		ctx.Header("Content-Type", "text/event-stream")
+		ctx.Header("Cache-Control", "no-cache")
+		ctx.Header("Connection", "keep-alive")
+		ctx.Header("X-Accel-Buffering", "no") // disable proxy buffering
+		ctx.Status(http.StatusOK)
+		ctx.Writer.Flush()
+
+		client, err := cm.AddStream(sessionID, ctx.Writer)
+		if err != nil {
+			log.Println("Failed to register stream:", err)
+			return
+		}
+
+		cm.Subscribe(client, eventID, sections)
+		if lastSeq > 0 {
+			seq, resync, err := cm.Replay(ctx.Request.Context(), client, eventID, lastSeq)
+			if err != nil {
+				log.Printf("failed to replay event %d: %v", eventID, err)
+				resync = true
+			}
+			if resync {
+				client.Send(reply(dto.TypeResyncRequired, "", dto.ResyncPayload{EventID: eventID, Seq: seq}))
+			}
+		}
+
+		select {
+		case <-ctx.Request.Context().Done(): // the client went away
+		case <-client.Done(): // write failure or slow consumer
+		}
+		cm.RemoveConnection(client)
+		<-client.Done() // the writer must be done with the response before returning
+	}
+}
@@ -1,49 +1,100 @@
 package websocketapi
 
 import (
+	"errors"
 	"log"
 	"net/http"
+	"ticket-booking-backend/cmd/api/session"
 	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/domain/ticket"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	websocketlib "github.com/gorilla/websocket"
 )
 
-func WebsocketHandler(cm *websocket.ConnectionManager) gin.HandlerFunc {
+// TokenHandler issues the token to open /ws with when WS_TOKEN_SECRET is set
+func TokenHandler(sessionManager *session.SessionManager) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
-		sessionIDAny, exists := ctx.Get("session_id")
-		if !exists {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Session ID is required in cookie"})
+		if ctx.GetBool("session_new") {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Session is required"})
 			return
 		}
-		sessionID := sessionIDAny.(string)
+
+		token, expiresAt := sessionManager.IssueWSToken(ctx.GetString("session_id"))
+		ctx.JSON(http.StatusOK, gin.H{
+			"token":      token,
+			"expires_at": expiresAt,
+		})
+	}
+}
+
+func WebsocketHandler(cm *websocket.ConnectionManager, sessionManager *session.SessionManager,
+	ticketService *ticket.TicketService, validator *validator.Validate) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		// The session has to exist before the upgrade, one minted for this request has no reservations to notify
+		sessionID := ctx.GetString("session_id")
+		if sessionID == "" || ctx.GetBool("session_new") {
+			ctx.JSON(http.StatusUnauthorized, gin.H{"error": "Session is required"})
+			return
+		}
+
+		if sessionManager.WSTokenRequired() {
+			if err := sessionManager.VerifyWSToken(ctx.Query("token"), sessionID); err != nil {
+				ctx.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+				return
+			}
+		}
 
 		wsConn, err := cm.CreateConnection(ctx.Writer, ctx.Request, nil)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to establish WebSocket connection"})
+			// The upgrader already replied, e.g. 403 for an origin that is not allowed
+			log.Println("Upgrade error:", err)
 			return
 		}
 		defer wsConn.Close()
 
-		err = cm.AddConnection(sessionID, wsConn)
+		client, err := cm.AddConnection(sessionID, wsConn)
 		if err != nil {
-			ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to register WebSocket connection"})
+			// The connection is upgraded, the reason goes in the close frame
+			log.Println("Failed to register WebSocket connection:", err)
+			code, reason := websocketlib.CloseInternalServerErr, "failed to register connection"
+			if errors.Is(err, websocket.ErrTooManyConnections) {
+				code, reason = websocketlib.ClosePolicyViolation, "too many connections"
+			}
+			closeMessage := websocketlib.FormatCloseMessage(code, reason)
+			if err := wsConn.WriteControl(websocketlib.CloseMessage, closeMessage, time.Now().Add(time.Second)); err != nil {
+				log.Println("Failed to send close frame:", err)
+			}
 			return
 		}
-		defer cm.RemoveConnection(sessionID)
+		defer cm.RemoveConnection(client)
 
-		// Read the message sent from frontend over websocket (for testing)
+		// Read the commands sent from frontend over websocket, see dto.Envelope.
+		// Reads time out when the client stops answering pings.
 		for {
-			messageType, p, err := wsConn.ReadMessage()
+			_, p, err := wsConn.ReadMessage()
 			if err != nil {
 				log.Println("Read error:", err)
 				return
 			}
 
-			// Echo the received message back to the client
-			if err := wsConn.WriteMessage(messageType, p); err != nil {
+			reply := handleMessage(cm, client, ticketService, validator, p)
+			if reply == nil {
+				continue
+			}
+
+			if err := client.Send(reply); err != nil {
 				log.Println("Write error:", err)
 				return
 			}
 		}
 	}
 }
+
+func StatsHandler(cm *websocket.ConnectionManager) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, cm.Stats())
+	}
+}
@@ -0,0 +1,66 @@
+package websocketapi
+
+import (
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"ticket-booking-backend/cmd/api/session"
+	"ticket-booking-backend/cmd/api/websocket"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	websocketlib "github.com/gorilla/websocket"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+func TestWebsocketHandlerTooManyConnections(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("BROADCAST_WINDOW_MS", "0")
+	redisClient := redislib.NewClient(&redislib.Options{Addr: miniredis.RunT(t).Addr()})
+	defer redisClient.Close()
+
+	cm := websocket.NewConnectionManager(redisClient, nil)
+	router := gin.New()
+	router.GET("/ws", func(ctx *gin.Context) {
+		ctx.Set("session_id", "session-1")
+		ctx.Next()
+	}, WebsocketHandler(cm, session.NewSessionManager(redisClient, time.Minute, ""), nil, nil))
+
+	server := httptest.NewServer(router)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	// Open connections until one is refused, the accepted ones stay open
+	for i := 0; i < 20; i++ {
+		conn, _, err := websocketlib.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatalf("Dial() error = %v", err)
+		}
+		defer conn.Close()
+
+		conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		_, _, err = conn.ReadMessage()
+
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			continue // accepted
+		}
+
+		var closeErr *websocketlib.CloseError
+		if !errors.As(err, &closeErr) {
+			t.Fatalf("connection %d: ReadMessage() error = %v, want a close frame", i+1, err)
+		}
+		if closeErr.Code != websocketlib.ClosePolicyViolation || closeErr.Text != "too many connections" {
+			t.Errorf("connection %d: close frame = %d %q, want %d %q", i+1, closeErr.Code, closeErr.Text,
+				websocketlib.ClosePolicyViolation, "too many connections")
+		}
+		if accepted := len(cm.GetConnections("session-1")); accepted != i {
+			t.Errorf("accepted connections = %d, want %d", accepted, i)
+		}
+		return
+	}
+	t.Fatal("no connection was refused")
+}
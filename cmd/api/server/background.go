@@ -1,6 +1,10 @@
 package server
 
-import "log"
+import (
+	"context"
+	"log"
+	"time"
+)
 
 func (s *Server) StartConsumers() {
 	go func() {
@@ -9,6 +13,18 @@ func (s *Server) StartConsumers() {
 		}
 	}()
 
+	go func() {
+		if err := s.mq.ConsumeMessages("pay", s.services.paymentService.HandlePaymentMessage); err != nil {
+			log.Printf("Failed to start payment consumer: %v", err)
+		}
+	}()
+
+	// Deliver notifications and broadcasts published by any instance to our sockets
+	go s.ConnectionManager.StartSubscriber(context.Background())
+
+	// Put seats of abandoned carts back on sale
+	go s.services.ticketService.StartHoldReaper(context.Background(), time.Second)
+
 	// go func() {
 	// 	if err := s.mq.ConsumeMessages("broadcast", s.services.ticketService.HandleBroadcastMessage); err != nil {
 	// 		log.Printf("Failed to start broadcast consumer: %v", err)
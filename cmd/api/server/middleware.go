@@ -1,50 +1,155 @@
 package server
 
 import (
+	"context"
+	"crypto/subtle"
 	"fmt"
 	"log"
+	"net/http"
+	"strings"
+	"ticket-booking-backend/cmd/api/session"
+	"ticket-booking-backend/tool/util"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 func (s *Server) AddMiddlewares() {
-	s.router.Use(addHeaders())
-	s.router.Use(addSessionMiddleware())
+	s.router.Use(addHeaders(getAPIOrigins()))
+	s.router.Use(addSessionMiddleware(s.sessionManager, getSessionAdoptionDeadline()))
 	s.router.Use(gin.Recovery())
 }
 
-func addHeaders() gin.HandlerFunc {
+// getAllowedOrigins reads ALLOWED_ORIGINS, a comma separated list of the origins of our frontends
+func getAllowedOrigins() []string {
+	return getOrigins("ALLOWED_ORIGINS", "http://localhost:8080")
+}
+
+// getAPIOrigins reads API_ORIGINS, a comma separated list of the origins browsers reach this API at,
+// behind a proxy or a load balancer they differ from the listening address
+func getAPIOrigins() []string {
+	return getOrigins("API_ORIGINS", "http://localhost:8080")
+}
+
+func getOrigins(key, defaultValue string) []string {
+	var origins []string
+	for _, origin := range strings.Split(util.GetEnvOrDefault(key, defaultValue), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// getConnectSrc lets pages connect to the API over HTTP (requests, Server-Sent Events) and WebSocket:
+// http(s)://host also allows ws(s)://host
+func getConnectSrc(apiOrigins []string) string {
+	connectSrc := []string{"'self'"}
+	for _, origin := range apiOrigins {
+		connectSrc = append(connectSrc, origin)
+		if strings.HasPrefix(origin, "http") {
+			connectSrc = append(connectSrc, "ws"+strings.TrimPrefix(origin, "http"))
+		}
+	}
+	return strings.Join(connectSrc, " ")
+}
+
+func addHeaders(apiOrigins []string) gin.HandlerFunc {
+	csp := fmt.Sprintf("default-src 'self'; connect-src %s;", getConnectSrc(apiOrigins))
+
 	return func(ctx *gin.Context) {
-		// Use the following header to allow WebSocket connections
-		ctx.Header("Content-Security-Policy", "default-src 'self'; connect-src 'self' ws://localhost:8080;")
+		ctx.Header("Content-Security-Policy", csp)
 		ctx.Next()
 	}
 }
 
-func addSessionMiddleware() gin.HandlerFunc {
+// getSessionAdoptionDeadline reads SESSION_ADOPTION_UNTIL, an RFC 3339 time.
+// Until then, sessions issued before the session registry existed are still accepted, see addSessionMiddleware.
+func getSessionAdoptionDeadline() time.Time {
+	value := util.GetEnvOrDefault("SESSION_ADOPTION_UNTIL", "")
+	if value == "" {
+		return time.Time{}
+	}
+
+	adoptUntil, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		log.Fatalf("Invalid SESSION_ADOPTION_UNTIL %q, should be an RFC 3339 time: %v", value, err)
+	}
+	return adoptUntil
+}
+
+// addSessionMiddleware sets session_id, issuing a new session when the cookie is missing, malformed
+// or unknown to the session registry: only sessions issued by the server are accepted.
+// Until adoptUntil, a well-formed unknown cookie is adopted instead, so the holds of sessions
+// issued before the registry existed survive the migration.
+// session_new tells whether the session was issued by this request.
+func addSessionMiddleware(sessionManager *session.SessionManager, adoptUntil time.Time) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		sessionID, err := ctx.Cookie("session_id")
+		if err != nil || !isValidSessionID(sessionID) {
+			sessionID = ""
+		}
+
+		redisCtx, cancel := context.WithTimeout(ctx.Request.Context(), time.Second)
+		defer cancel()
+
+		registered := false
+		if sessionID != "" {
+			registered, err = sessionManager.Touch(redisCtx, sessionID)
+			if err != nil {
+				log.Printf("failed to touch session: %v", err)
+				registered = true // keep the session rather than logging users out while Redis is unavailable
+			}
+		}
 
-		if err != nil || sessionID == "" {
-			newSessionID := generateSessionID()
+		adopted := sessionID != "" && !registered && time.Now().Before(adoptUntil)
 
-			ctx.SetCookie("session_id", newSessionID, 3600, "/", "", false, true)
-			ctx.Set("session_id", newSessionID)
-		} else {
-			ctx.Set("session_id", sessionID)
+		isNew := !registered && !adopted
+		if isNew {
+			sessionID = session.NewID()
 		}
 
-		s, e := ctx.Get("session_id")
-		if e == false {
-			log.Fatal(e)
+		if !registered {
+			if err := sessionManager.Create(redisCtx, sessionID); err != nil {
+				log.Printf("failed to create session: %v", err)
+				ctx.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to create session"})
+				return
+			}
 		}
-		fmt.Printf("session_id:%s", s)
+
+		if isNew {
+			session.SetCookie(ctx, sessionID)
+		}
+		ctx.Set("session_id", sessionID)
+		ctx.Set("session_new", isNew)
 
 		ctx.Next()
 	}
 }
 
-func generateSessionID() string {
-	return uuid.NewString()
+func isValidSessionID(sessionID string) bool {
+	_, err := uuid.Parse(sessionID)
+	return err == nil
+}
+
+// addAdminAuth protects admin routes with the ADMIN_TOKEN sent in the X-Admin-Token header.
+// Admin routes are disabled when ADMIN_TOKEN is not set.
+func addAdminAuth() gin.HandlerFunc {
+	adminToken := util.GetEnvOrDefault("ADMIN_TOKEN", "")
+
+	return func(ctx *gin.Context) {
+		if adminToken == "" {
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "admin API is disabled"})
+			return
+		}
+
+		token := ctx.GetHeader("X-Admin-Token")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid admin token"})
+			return
+		}
+
+		ctx.Next()
+	}
 }
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"ticket-booking-backend/cmd/api/session"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/gin-gonic/gin"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+func TestSessionMiddleware(t *testing.T) {
+	const registeredID = "6f1c2a9e-3b1d-4c55-9f2e-0c8a7d5b4e21"
+	const unknownID = "0b6f4a1c-8d2e-4f3a-9c5b-7e1d2a3f4b5c"
+
+	tests := []struct {
+		name         string
+		cookie       string
+		redisDown    bool
+		adoptUntil   time.Time
+		wantStatus   int
+		wantSameID   bool // the cookie's session is kept
+		wantNew      bool
+		wantCookie   bool // a session_id cookie is set on the response
+		wantRegistry bool // the session is registered in Redis afterwards
+	}{
+		{name: "no cookie issues a session", wantStatus: http.StatusOK, wantNew: true, wantCookie: true, wantRegistry: true},
+		{name: "registered session is kept", cookie: registeredID, wantStatus: http.StatusOK, wantSameID: true, wantRegistry: true},
+		{name: "unknown well-formed session is replaced", cookie: unknownID, wantStatus: http.StatusOK, wantNew: true, wantCookie: true, wantRegistry: true},
+		{
+			name: "unknown session is adopted during the migration", cookie: unknownID, adoptUntil: time.Now().Add(time.Hour),
+			wantStatus: http.StatusOK, wantSameID: true, wantRegistry: true,
+		},
+		{
+			name: "unknown session is replaced after the migration", cookie: unknownID, adoptUntil: time.Now().Add(-time.Hour),
+			wantStatus: http.StatusOK, wantNew: true, wantCookie: true, wantRegistry: true,
+		},
+		{name: "malformed cookie is replaced", cookie: "not-a-session", wantStatus: http.StatusOK, wantNew: true, wantCookie: true, wantRegistry: true},
+		{name: "session cannot be created", redisDown: true, wantStatus: http.StatusInternalServerError},
+		{name: "session is kept while redis is down", cookie: registeredID, redisDown: true, wantStatus: http.StatusOK, wantSameID: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			redisClient := redislib.NewClient(&redislib.Options{Addr: mr.Addr(), MaxRetries: -1})
+			sessionManager := session.NewSessionManager(redisClient, time.Minute, "")
+			t.Cleanup(func() { sessionManager.Close() })
+			if err := sessionManager.Create(context.Background(), registeredID); err != nil {
+				t.Fatalf("Create() error = %v", err)
+			}
+			if tt.redisDown {
+				mr.Close()
+			}
+
+			gin.SetMode(gin.TestMode)
+			router := gin.New()
+			router.Use(addSessionMiddleware(sessionManager, tt.adoptUntil))
+			var gotID string
+			var gotNew bool
+			router.GET("/", func(ctx *gin.Context) {
+				gotID = ctx.GetString("session_id")
+				gotNew = ctx.GetBool("session_new")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session_id", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			if sameID := gotID == tt.cookie; sameID != tt.wantSameID {
+				t.Errorf("session_id = %q, cookie %q", gotID, tt.cookie)
+			}
+			if gotNew != tt.wantNew {
+				t.Errorf("session_new = %v, want %v", gotNew, tt.wantNew)
+			}
+			if gotCookie := rec.Header().Get("Set-Cookie") != ""; gotCookie != tt.wantCookie {
+				t.Errorf("cookie set = %v, want %v", gotCookie, tt.wantCookie)
+			}
+			if !tt.redisDown {
+				if registered := mr.Exists("session:" + gotID); registered != tt.wantRegistry {
+					t.Errorf("session registered = %v, want %v", registered, tt.wantRegistry)
+				}
+			}
+		})
+	}
+}
+
+func TestGetConnectSrc(t *testing.T) {
+	tests := []struct {
+		name       string
+		apiOrigins []string
+		want       string
+	}{
+		{name: "same origin only", want: "'self'"},
+		{name: "http api", apiOrigins: []string{"http://localhost:8080"}, want: "'self' http://localhost:8080 ws://localhost:8080"},
+		{name: "https api", apiOrigins: []string{"https://api.example.com"}, want: "'self' https://api.example.com wss://api.example.com"},
+		{name: "websocket origin", apiOrigins: []string{"wss://ws.example.com"}, want: "'self' wss://ws.example.com"},
+		{
+			name:       "several origins",
+			apiOrigins: []string{"https://api.example.com", "https://eu.api.example.com"},
+			want:       "'self' https://api.example.com wss://api.example.com https://eu.api.example.com wss://eu.api.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getConnectSrc(tt.apiOrigins); got != tt.want {
+				t.Errorf("getConnectSrc(%v) = %q, want %q", tt.apiOrigins, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"ticket-booking-backend/cmd/api/session"
 	"ticket-booking-backend/cmd/api/websocket"
 	"ticket-booking-backend/domain/artist"
+	"ticket-booking-backend/domain/booking"
 	"ticket-booking-backend/domain/event"
+	"ticket-booking-backend/domain/payment"
 	"ticket-booking-backend/domain/ticket"
 	"ticket-booking-backend/domain/user"
 	"ticket-booking-backend/domain/venue"
@@ -26,12 +28,15 @@ type Server struct {
 	services          Services
 	validator         *validator.Validate
 	sessionManager    *session.SessionManager
+	allowedOrigins    []string // origins of our frontends, for CORS-like checks such as the WebSocket upgrade
 	ConnectionManager *websocket.ConnectionManager
 }
 type Services struct {
-	ticketService *ticket.TicketService
-	venueService  *venue.VenueService
-	userService   *user.UserService
-	artistService *artist.ArtistService
-	eventService  *event.EventService
+	ticketService  *ticket.TicketService
+	venueService   *venue.VenueService
+	userService    *user.UserService
+	artistService  *artist.ArtistService
+	eventService   *event.EventService
+	bookingService *booking.BookingService
+	paymentService *payment.PaymentService
 }
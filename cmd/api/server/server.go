@@ -2,8 +2,10 @@ package server
 
 import (
 	"log"
+	"ticket-booking-backend/cmd/api/domain/adminapi"
 	"ticket-booking-backend/cmd/api/domain/artistapi"
 	"ticket-booking-backend/cmd/api/domain/eventapi"
+	"ticket-booking-backend/cmd/api/domain/paymentapi"
 	"ticket-booking-backend/cmd/api/domain/ticketapi"
 	"ticket-booking-backend/cmd/api/domain/userapi"
 	"ticket-booking-backend/cmd/api/domain/venueapi"
@@ -11,13 +13,16 @@ import (
 	"ticket-booking-backend/cmd/api/session"
 	"ticket-booking-backend/cmd/api/websocket"
 	"ticket-booking-backend/domain/artist"
+	"ticket-booking-backend/domain/booking"
 	"ticket-booking-backend/domain/event"
+	"ticket-booking-backend/domain/payment"
 	"ticket-booking-backend/domain/ticket"
 	"ticket-booking-backend/domain/user"
 	"ticket-booking-backend/domain/venue"
 	"ticket-booking-backend/tool/rabbitmq"
 	"ticket-booking-backend/tool/redis"
 	"ticket-booking-backend/tool/sqldb"
+	"ticket-booking-backend/tool/util"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -26,36 +31,58 @@ import (
 
 func NewServer() *Server {
 	redisClient := redis.InitRedis()
+	allowedOrigins := getAllowedOrigins()
 	return &Server{
 		router:            gin.Default(),
 		redisClient:       redisClient,
 		db:                sqldb.InitPostgres(),
 		mq:                rabbitmq.InitRabbitMQ(),
-		sessionManager:    session.NewSessionManager(redisClient, time.Minute*30),
+		sessionManager:    session.NewSessionManager(redisClient, time.Minute*30, util.GetEnvOrDefault("WS_TOKEN_SECRET", "")),
+		allowedOrigins:    allowedOrigins,
 		validator:         validator.New(),
-		ConnectionManager: websocket.NewConnectionManager(redisClient),
+		ConnectionManager: websocket.NewConnectionManager(redisClient, allowedOrigins),
 	}
 }
 
 func (s *Server) InitServices() {
+	venueService := venue.NewVenueService(s.db)
 	s.services = Services{
-		ticketService: ticket.NewTicketService(s.redisClient, s.mq, s.db),
-		venueService:  venue.NewVenueService(s.db),
-		userService:   user.NewUserService(s.db),
-		artistService: artist.NewArtistService(s.db),
-		eventService:  event.NewEventService(s.db),
+		ticketService:  ticket.NewTicketService(s.redisClient, s.mq, s.db, venueService, s.ConnectionManager),
+		venueService:   venueService,
+		userService:    user.NewUserService(s.db),
+		artistService:  artist.NewArtistService(s.db),
+		eventService:   event.NewEventService(s.db),
+		bookingService: booking.NewBookingService(s.db),
 	}
+
+	// Todo: plug in a real payment gateway
+	s.services.paymentService = payment.NewPaymentService(s.mq, payment.NewFakeProvider(),
+		s.services.ticketService, s.services.bookingService, s.services.userService, s.ConnectionManager)
 }
 
 func (s *Server) SetupRoutes() {
 	s.router.POST("/events/:event_id/seats/set-price", eventapi.SetSeatsPriceHandler(s.services.eventService, s.services.venueService, s.validator))
 	s.router.GET("/events/:event_id/tickets", ticketapi.GetTicketsHandler(s.services.ticketService, s.services.venueService, s.services.eventService, s.validator))
 	s.router.POST("/events/:event_id/tickets/reserve", ticketapi.ReserveHandler(s.services.ticketService, s.services.eventService, s.validator))
+	s.router.POST("/events/:event_id/checkout", paymentapi.CheckoutHandler(s.services.paymentService, s.services.eventService))
+	s.router.DELETE("/events/:event_id/reservations", ticketapi.CancelAllHoldsHandler(s.services.ticketService, s.services.eventService))
+	s.router.DELETE("/events/:event_id/reservations/:hold_id", ticketapi.CancelHoldHandler(s.services.ticketService, s.services.eventService))
+	s.router.POST("/events/:event_id/reservations/:hold_id/pay", paymentapi.PayHandler(s.services.paymentService, s.services.eventService))
+	s.router.GET("/reservations/requests/:id", ticketapi.GetReservationRequestHandler(s.services.ticketService))
+	s.router.GET("/me/reservations", ticketapi.ListHoldsHandler(s.services.ticketService, s.services.venueService, s.services.eventService))
 	s.router.POST("/venues", venueapi.CreateVenueHandler(s.services.venueService, s.validator))
 	s.router.POST("/artists", artistapi.CreateArtistHandler(s.services.artistService, s.validator))
 	s.router.POST("/events", eventapi.CreateEventHandler(s.services.eventService, s.services.venueService, s.services.artistService, s.validator))
 	s.router.POST("/users", userapi.CreateUserHandler(s.services.userService, s.validator))
-	s.router.GET("/ws", websocketapi.WebsocketHandler(s.ConnectionManager)) // get notification: tickets unavailable/available, ticket reserved
+	s.router.POST("/login", userapi.LoginHandler(s.services.userService, s.sessionManager, s.services.ticketService, s.validator)) // binds a new session to the user
+	s.router.GET("/ws/token", websocketapi.TokenHandler(s.sessionManager))
+	s.router.GET("/events/:event_id/stream", websocketapi.StreamHandler(s.ConnectionManager, s.services.eventService))               // Server-Sent Events fallback of /ws
+	s.router.GET("/ws", websocketapi.WebsocketHandler(s.ConnectionManager, s.sessionManager, s.services.ticketService, s.validator)) // get notification: tickets unavailable/available, ticket reserved
+
+	admin := s.router.Group("/admin", addAdminAuth())
+	admin.GET("/dead-letters/:action", adminapi.GetDeadLettersHandler(s.mq))            // action: book or pay
+	admin.POST("/dead-letters/:action/replay", adminapi.ReplayDeadLettersHandler(s.mq)) // move back to the original queue
+	admin.GET("/ws/stats", websocketapi.StatsHandler(s.ConnectionManager))
 }
 
 func (s *Server) Run(port string) error {
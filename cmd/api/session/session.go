@@ -1,23 +1,109 @@
 package session
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	redislib "github.com/redis/go-redis/v9"
 )
 
+var (
+	ErrInvalidWSToken = errors.New("invalid ws token")
+	ErrExpiredWSToken = errors.New("ws token expired")
+)
+
+// wsTokenTTL is short: the token is fetched right before opening the socket
+var wsTokenTTL = time.Minute
+
 type SessionManager struct {
-	redisClient *redislib.Client
-	sessionTTL  time.Duration
+	redisClient   *redislib.Client
+	sessionTTL    time.Duration
+	wsTokenSecret []byte // ws tokens are not required when empty
 }
 
-func NewSessionManager(redisClient *redislib.Client, sessionTTL time.Duration) *SessionManager {
+func NewSessionManager(redisClient *redislib.Client, sessionTTL time.Duration, wsTokenSecret string) *SessionManager {
 	return &SessionManager{
-		redisClient: redisClient,
-		sessionTTL:  sessionTTL,
+		redisClient:   redisClient,
+		sessionTTL:    sessionTTL,
+		wsTokenSecret: []byte(wsTokenSecret),
 	}
 }
 
+// NewID returns a new session ID, register it with Create
+func NewID() string {
+	return uuid.NewString()
+}
+
+// SetCookie hands the session ID to the browser
+func SetCookie(ctx *gin.Context, sessionID string) {
+	ctx.SetCookie("session_id", sessionID, 3600, "/", "", false, true)
+}
+
+func getSessionKey(sessionID string) string {
+	return fmt.Sprintf("session:%s", sessionID)
+}
+
+// Create registers a session issued by the server
+func (s *SessionManager) Create(ctx context.Context, sessionID string) error {
+	return s.redisClient.Set(ctx, getSessionKey(sessionID), time.Now().Unix(), s.sessionTTL).Err()
+}
+
+// Delete unregisters a session, its cookie is not accepted anymore
+func (s *SessionManager) Delete(ctx context.Context, sessionID string) error {
+	return s.redisClient.Del(ctx, getSessionKey(sessionID)).Err()
+}
+
+// Touch extends the session and reports whether the server issued it and it has not expired
+func (s *SessionManager) Touch(ctx context.Context, sessionID string) (bool, error) {
+	return s.redisClient.Expire(ctx, getSessionKey(sessionID), s.sessionTTL).Result()
+}
+
+func (s *SessionManager) WSTokenRequired() bool {
+	return len(s.wsTokenSecret) > 0
+}
+
+// IssueWSToken signs a token binding the session to an expiry, "<expiry unix>.<signature>".
+// Only pages of our origins can read it, so a socket opened with it was opened by one of them.
+func (s *SessionManager) IssueWSToken(sessionID string) (string, time.Time) {
+	expiresAt := time.Now().Add(wsTokenTTL)
+	expiry := strconv.FormatInt(expiresAt.Unix(), 10)
+	return expiry + "." + s.sign(sessionID, expiry), expiresAt
+}
+
+func (s *SessionManager) VerifyWSToken(token, sessionID string) error {
+	expiry, signature, found := strings.Cut(token, ".")
+	if !found {
+		return ErrInvalidWSToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(s.sign(sessionID, expiry))) {
+		return ErrInvalidWSToken
+	}
+
+	expiresAt, err := strconv.ParseInt(expiry, 10, 64)
+	if err != nil {
+		return ErrInvalidWSToken
+	}
+	if time.Now().Unix() > expiresAt {
+		return ErrExpiredWSToken
+	}
+	return nil
+}
+
+func (s *SessionManager) sign(sessionID, expiry string) string {
+	mac := hmac.New(sha256.New, s.wsTokenSecret)
+	mac.Write([]byte(sessionID + "|" + expiry))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
 func (s *SessionManager) Close() error {
 	if err := s.redisClient.Close(); err != nil {
 		return err
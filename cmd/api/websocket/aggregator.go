@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"log"
+	"sort"
+	"sync"
+	"ticket-booking-backend/dto"
+	"time"
+)
+
+// broadcastKey identifies an availability value, later updates of the same key replace earlier ones
+type broadcastKey struct {
+	sectionID int
+	rowID     int
+	price     int
+}
+
+// broadcastAggregator coalesces the availability updates of each event over a short window,
+// then appends them as a single broadcast, instead of one tiny broadcast per reservation
+type broadcastAggregator struct {
+	window  time.Duration
+	flushFn func(eventID int, broadcastMsgs dto.BroadcastMsgs) error
+	pending map[int]map[broadcastKey]dto.BroadcastMsg // event ID -> latest values
+	lock    sync.Mutex
+}
+
+func newBroadcastAggregator(window time.Duration, flushFn func(int, dto.BroadcastMsgs) error) *broadcastAggregator {
+	return &broadcastAggregator{
+		window:  window,
+		flushFn: flushFn,
+		pending: make(map[int]map[broadcastKey]dto.BroadcastMsg),
+	}
+}
+
+// add keeps the latest max_length of each (section, row, price), the first update of an event opens its window
+func (a *broadcastAggregator) add(msgs []dto.BroadcastMsg) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	for _, msg := range msgs {
+		values, exists := a.pending[msg.EventID]
+		if !exists {
+			values = make(map[broadcastKey]dto.BroadcastMsg)
+			a.pending[msg.EventID] = values
+
+			eventID := msg.EventID
+			time.AfterFunc(a.window, func() { a.flush(eventID) })
+		}
+		values[broadcastKey{sectionID: msg.SectionID, rowID: msg.RowID, price: msg.Price}] = msg
+	}
+}
+
+func (a *broadcastAggregator) flush(eventID int) {
+	a.lock.Lock()
+	values := a.pending[eventID]
+	delete(a.pending, eventID)
+	a.lock.Unlock()
+
+	var broadcastMsgs dto.BroadcastMsgs
+	for _, msg := range values {
+		broadcastMsgs.Messages = append(broadcastMsgs.Messages, msg)
+	}
+	sort.Slice(broadcastMsgs.Messages, func(i, j int) bool {
+		left, right := broadcastMsgs.Messages[i], broadcastMsgs.Messages[j]
+		if left.SectionID != right.SectionID {
+			return left.SectionID < right.SectionID
+		}
+		if left.RowID != right.RowID {
+			return left.RowID < right.RowID
+		}
+		return left.Price < right.Price
+	})
+
+	if err := a.flushFn(eventID, broadcastMsgs); err != nil {
+		log.Printf("Failed to broadcast availability of event %d: %v", eventID, err)
+	}
+}
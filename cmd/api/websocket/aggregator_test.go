@@ -0,0 +1,125 @@
+package websocket
+
+import (
+	"reflect"
+	"testing"
+	"ticket-booking-backend/dto"
+	"time"
+)
+
+type flushed struct {
+	eventID       int
+	broadcastMsgs dto.BroadcastMsgs
+	at            time.Time
+}
+
+func newTestAggregator(window time.Duration) (*broadcastAggregator, chan flushed) {
+	flushes := make(chan flushed, 10)
+	aggregator := newBroadcastAggregator(window, func(eventID int, broadcastMsgs dto.BroadcastMsgs) error {
+		flushes <- flushed{eventID: eventID, broadcastMsgs: broadcastMsgs, at: time.Now()}
+		return nil
+	})
+	return aggregator, flushes
+}
+
+func availability(eventID, sectionID, rowID, price, maxLength int) dto.BroadcastMsg {
+	return dto.BroadcastMsg{EventID: eventID, SectionID: sectionID, RowID: rowID, Price: price, MaxLength: maxLength}
+}
+
+func TestBroadcastAggregatorFlush(t *testing.T) {
+	const window = 20 * time.Millisecond
+
+	tests := []struct {
+		name string
+		adds [][]dto.BroadcastMsg // added one after the other within the window
+		want map[int][]dto.BroadcastMsg
+	}{
+		{
+			name: "single update",
+			adds: [][]dto.BroadcastMsg{{availability(1, 2, 3, 100, 4)}},
+			want: map[int][]dto.BroadcastMsg{1: {availability(1, 2, 3, 100, 4)}},
+		},
+		{
+			name: "latest value of a row and price wins",
+			adds: [][]dto.BroadcastMsg{{availability(1, 2, 3, 100, 4)}, {availability(1, 2, 3, 100, 2)}},
+			want: map[int][]dto.BroadcastMsg{1: {availability(1, 2, 3, 100, 2)}},
+		},
+		{
+			name: "sorted by section, row and price",
+			adds: [][]dto.BroadcastMsg{
+				{availability(1, 5, 1, 100, 1), availability(1, 2, 7, 200, 2)},
+				{availability(1, 2, 7, 100, 3), availability(1, 2, 3, 300, 4)},
+			},
+			want: map[int][]dto.BroadcastMsg{1: {
+				availability(1, 2, 3, 300, 4),
+				availability(1, 2, 7, 100, 3),
+				availability(1, 2, 7, 200, 2),
+				availability(1, 5, 1, 100, 1),
+			}},
+		},
+		{
+			name: "each event is flushed on its own",
+			adds: [][]dto.BroadcastMsg{{availability(1, 2, 3, 100, 4), availability(9, 2, 3, 100, 1)}},
+			want: map[int][]dto.BroadcastMsg{
+				1: {availability(1, 2, 3, 100, 4)},
+				9: {availability(9, 2, 3, 100, 1)},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			aggregator, flushes := newTestAggregator(window)
+
+			start := time.Now()
+			for _, msgs := range tt.adds {
+				aggregator.add(msgs)
+			}
+
+			got := make(map[int][]dto.BroadcastMsg)
+			for range tt.want {
+				select {
+				case f := <-flushes:
+					if _, exists := got[f.eventID]; exists {
+						t.Fatalf("event %d flushed twice", f.eventID)
+					}
+					if f.at.Sub(start) < window {
+						t.Errorf("event %d flushed after %v, before the %v window", f.eventID, f.at.Sub(start), window)
+					}
+					got[f.eventID] = f.broadcastMsgs.Messages
+				case <-time.After(time.Second):
+					t.Fatalf("got %d flushes, want %d", len(got), len(tt.want))
+				}
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("flushed %+v, want %+v", got, tt.want)
+			}
+
+			select {
+			case f := <-flushes:
+				t.Errorf("unexpected flush of event %d", f.eventID)
+			case <-time.After(2 * window):
+			}
+		})
+	}
+}
+
+func TestBroadcastAggregatorOpensNewWindow(t *testing.T) {
+	const window = 20 * time.Millisecond
+	aggregator, flushes := newTestAggregator(window)
+
+	for i, maxLength := range []int{4, 2} {
+		aggregator.add([]dto.BroadcastMsg{availability(1, 2, 3, 100, maxLength)})
+
+		select {
+		case f := <-flushes:
+			want := []dto.BroadcastMsg{availability(1, 2, 3, 100, maxLength)}
+			if !reflect.DeepEqual(f.broadcastMsgs.Messages, want) {
+				t.Errorf("window %d flushed %+v, want %+v", i, f.broadcastMsgs.Messages, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("window %d was not flushed", i)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+-- Numbers a broadcast of an event, keeps it in the event's stream for replay and publishes it.
+-- Done in one script so subscribers receive the messages of an event in sequence order.
+-- KEYS[1]: event:<event_id>:ws_seq
+-- KEYS[2]: event:<event_id>:ws_stream
+-- ARGV[1]: broadcast JSON
+-- ARGV[2]: max stream length
+-- ARGV[3]: ttl of both keys in seconds
+-- ARGV[4]: channel to publish on
+local seq = redis.call('INCR', KEYS[1])
+redis.call('XADD', KEYS[2], 'MAXLEN', '~', ARGV[2], seq .. '-0', 'data', ARGV[1])
+redis.call('EXPIRE', KEYS[1], ARGV[3])
+redis.call('EXPIRE', KEYS[2], ARGV[3])
+redis.call('PUBLISH', ARGV[4], '{"seq":' .. seq .. ',"data":' .. ARGV[1] .. '}')
+return seq
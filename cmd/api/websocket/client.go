@@ -0,0 +1,171 @@
+package websocket
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"ticket-booking-backend/dto"
+	"time"
+)
+
+var (
+	sendBufferSize       = 256              // outbound messages queued per connection
+	writeWait            = 10 * time.Second // time allowed to write a message to the peer
+	pongWait             = 60 * time.Second // time allowed to read the next pong from the peer
+	pingPeriod           = pongWait * 9 / 10
+	maxMessageSize int64 = 4096
+)
+
+var (
+	ErrClientClosed = errors.New("websocket client closed")
+	ErrSlowConsumer = errors.New("websocket client too slow, disconnected")
+)
+
+// Client is a WebSocket connection, or a Server-Sent Events stream, and the events it watches.
+// Messages are written by a single writer goroutine from a bounded queue,
+// so a stalled peer never blocks the caller of Send.
+type Client struct {
+	sessionID         string
+	transport         transport
+	subscriptions     map[int]map[int]bool // event ID -> section IDs, an empty set means every section
+	subscriptionsLock sync.RWMutex
+	send              chan []byte
+	done              chan struct{}
+	writerDone        chan struct{} // closed once the writer no longer touches the transport
+	closeOnce         sync.Once
+	onClose           func(*Client)
+}
+
+// newClient starts the writer
+func newClient(sessionID string, transport transport, onClose func(*Client)) *Client {
+	client := &Client{
+		sessionID:     sessionID,
+		transport:     transport,
+		subscriptions: make(map[int]map[int]bool),
+		send:          make(chan []byte, sendBufferSize),
+		done:          make(chan struct{}),
+		writerDone:    make(chan struct{}),
+		onClose:       onClose,
+	}
+
+	go client.writePump()
+	return client
+}
+
+func (c *Client) SessionID() string {
+	return c.sessionID
+}
+
+// Send queues a text message for the connection. A client whose queue is full is disconnected.
+func (c *Client) Send(data []byte) error {
+	select {
+	case <-c.done:
+		return ErrClientClosed
+	default:
+	}
+
+	select {
+	case c.send <- data:
+		return nil
+	case <-c.done:
+		return ErrClientClosed
+	default:
+		log.Printf("Send buffer full for session %s, disconnecting", c.sessionID)
+		c.Close()
+		return ErrSlowConsumer
+	}
+}
+
+// Done is closed when the client is closed and its writer has stopped
+func (c *Client) Done() <-chan struct{} {
+	return c.writerDone
+}
+
+// Close stops the writer, closes the connection and unregisters the client
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.transport.close()
+		if c.onClose != nil {
+			c.onClose(c)
+		}
+	})
+}
+
+// writePump is the only goroutine writing to the connection, as gorilla/websocket requires
+func (c *Client) writePump() {
+	ticker := time.NewTicker(pingPeriod)
+	defer ticker.Stop()
+	defer close(c.writerDone)
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			if err := c.transport.writeMessage(data); err != nil {
+				log.Printf("Failed to send message to connection: %v", err)
+				c.Close()
+				return
+			}
+		case <-ticker.C:
+			if err := c.transport.writePing(); err != nil {
+				log.Printf("Failed to ping connection: %v", err)
+				c.Close()
+				return
+			}
+		}
+	}
+}
+
+// subscribe replaces the sections watched for an event, no sections means the whole event
+func (c *Client) subscribe(eventID int, sectionIDs []int) {
+	c.subscriptionsLock.Lock()
+	defer c.subscriptionsLock.Unlock()
+
+	sections := make(map[int]bool)
+	for _, sectionID := range sectionIDs {
+		sections[sectionID] = true
+	}
+	c.subscriptions[eventID] = sections
+}
+
+func (c *Client) unsubscribe(eventID int) {
+	c.subscriptionsLock.Lock()
+	defer c.subscriptionsLock.Unlock()
+
+	delete(c.subscriptions, eventID)
+}
+
+func (c *Client) isSubscribed(eventID, sectionID int) bool {
+	c.subscriptionsLock.RLock()
+	defer c.subscriptionsLock.RUnlock()
+
+	sections, exists := c.subscriptions[eventID]
+	if !exists {
+		return false
+	}
+	return len(sections) == 0 || sections[sectionID]
+}
+
+// sendBroadcast queues the messages of the events and sections the client subscribed to, if any
+func (c *Client) sendBroadcast(broadcastMsgs dto.BroadcastMsgs) error {
+	filtered := dto.BroadcastMsgs{Seq: broadcastMsgs.Seq}
+	for _, msg := range broadcastMsgs.Messages {
+		if c.isSubscribed(msg.EventID, msg.SectionID) {
+			filtered.Messages = append(filtered.Messages, msg)
+		}
+	}
+	if len(filtered.Messages) == 0 {
+		return nil
+	}
+
+	envelope, err := dto.NewEnvelope(dto.TypeAvailability, "", filtered)
+	if err != nil {
+		return fmt.Errorf("failed to marshal broadcast msgs:%+w", err)
+	}
+
+	// Only queues the message, never waits on the peer
+	return c.Send(envelope)
+}
@@ -0,0 +1,63 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Redis channels every API instance subscribes to, so messages reach
+// connections held by any replica.
+const (
+	broadcastChannel = "ws:broadcast"
+	notifyChannel    = "ws:notify"
+)
+
+// sessionMessage is published on notifyChannel
+type sessionMessage struct {
+	SessionID string          `json:"session_id"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (cm *ConnectionManager) publish(channel string, data []byte) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := cm.redisClient.Publish(ctx, channel, data).Err(); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", channel, err)
+	}
+	return nil
+}
+
+// StartSubscriber delivers published messages to the local connections until ctx is cancelled
+func (cm *ConnectionManager) StartSubscriber(ctx context.Context) {
+	pubsub := cm.redisClient.Subscribe(ctx, broadcastChannel, notifyChannel)
+	defer pubsub.Close()
+
+	// go-redis resubscribes on its own after a lost connection
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			switch msg.Channel {
+			case broadcastChannel:
+				cm.broadcastLocal([]byte(msg.Payload))
+			case notifyChannel:
+				var sessionMsg sessionMessage
+				if err := json.Unmarshal([]byte(msg.Payload), &sessionMsg); err != nil {
+					log.Printf("Failed to unmarshal session message: %v", err)
+					continue
+				}
+				cm.notifyLocal(sessionMsg.SessionID, sessionMsg.Data)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"ticket-booking-backend/dto"
+	"time"
+
+	redislib "github.com/redis/go-redis/v9"
+)
+
+// Broadcasts of an event are numbered and kept in a Redis stream, so a client
+// that lost its connection can ask for what it missed.
+var (
+	replayStreamMaxLen int64 = 1000 // broadcasts kept per event
+	maxReplay          int64 = 128  // broadcasts replayed at most, under sendBufferSize, past that a resync is cheaper
+	replayTTL                = 24 * time.Hour
+)
+
+//go:embed append_broadcast.lua
+var appendBroadcastLua string
+
+var appendBroadcastScript = redislib.NewScript(appendBroadcastLua)
+
+// sequencedBroadcast is published on broadcastChannel by append_broadcast.lua
+type sequencedBroadcast struct {
+	Seq  int64           `json:"seq"`
+	Data json.RawMessage `json:"data"`
+}
+
+func getEventSeqKey(eventID int) string {
+	return fmt.Sprintf("event:%d:ws_seq", eventID)
+}
+
+func getEventStreamKey(eventID int) string {
+	return fmt.Sprintf("event:%d:ws_stream", eventID)
+}
+
+// appendBroadcast numbers, stores and publishes the messages of a single event
+func (cm *ConnectionManager) appendBroadcast(eventID int, broadcastMsgs dto.BroadcastMsgs) error {
+	data, err := json.Marshal(broadcastMsgs)
+	if err != nil {
+		return fmt.Errorf("failed to marshal broadcast msgs:%+w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	keys := []string{getEventSeqKey(eventID), getEventStreamKey(eventID)}
+	args := []interface{}{data, replayStreamMaxLen, int(replayTTL.Seconds()), broadcastChannel}
+	if err := appendBroadcastScript.Run(ctx, cm.redisClient, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to append broadcast of event %d: %w", eventID, err)
+	}
+	return nil
+}
+
+// Replay sends the client the broadcasts of the event numbered after lastSeq.
+// It returns the current sequence number, and resync true when the missed
+// broadcasts are no longer kept and the client has to reload the availability.
+func (cm *ConnectionManager) Replay(ctx context.Context, client *Client, eventID int, lastSeq int64) (seq int64, resync bool, err error) {
+	seq, err = cm.redisClient.Get(ctx, getEventSeqKey(eventID)).Int64()
+	if err != nil && !errors.Is(err, redislib.Nil) {
+		return 0, false, fmt.Errorf("failed to get sequence of event %d: %w", eventID, err)
+	}
+	if lastSeq == seq {
+		return seq, false, nil
+	}
+	if lastSeq > seq { // the sequence started over, the client's number means nothing anymore
+		return seq, true, nil
+	}
+	if seq-lastSeq > maxReplay {
+		return seq, true, nil
+	}
+
+	entries, err := cm.redisClient.XRangeN(ctx, getEventStreamKey(eventID),
+		fmt.Sprintf("%d-0", lastSeq+1), "+", maxReplay).Result()
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read stream of event %d: %w", eventID, err)
+	}
+	if len(entries) == 0 || parseStreamSeq(entries[0].ID) != lastSeq+1 { // trimmed or expired
+		return seq, true, nil
+	}
+
+	for _, entry := range entries {
+		data, _ := entry.Values["data"].(string)
+		var broadcastMsgs dto.BroadcastMsgs
+		if err := json.Unmarshal([]byte(data), &broadcastMsgs); err != nil {
+			return 0, false, fmt.Errorf("failed to unmarshal replayed broadcast %s: %w", entry.ID, err)
+		}
+		broadcastMsgs.Seq = parseStreamSeq(entry.ID)
+
+		if err := client.sendBroadcast(broadcastMsgs); err != nil {
+			return 0, false, err
+		}
+	}
+	return seq, false, nil
+}
+
+// parseStreamSeq reads the sequence number of a stream entry ID, "<seq>-0"
+func parseStreamSeq(id string) int64 {
+	ms, _, _ := strings.Cut(id, "-")
+	seq, _ := strconv.ParseInt(ms, 10, 64)
+	return seq
+}
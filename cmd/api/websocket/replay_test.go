@@ -0,0 +1,102 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"ticket-booking-backend/dto"
+
+	"github.com/alicebob/miniredis/v2"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+func TestReplay(t *testing.T) {
+	const eventID = 1
+
+	tests := []struct {
+		name       string
+		broadcasts int // broadcasts appended before replaying
+		setup      func(t *testing.T, redisClient *redislib.Client)
+		maxReplay  int64
+		lastSeq    int64
+		wantSeq    int64
+		wantResync bool
+		wantSent   []int64 // sequence numbers replayed to the client
+	}{
+		{name: "up to date", broadcasts: 3, lastSeq: 3, wantSeq: 3},
+		{name: "no broadcast yet", lastSeq: 0, wantSeq: 0},
+		{name: "missed broadcasts are replayed in order", broadcasts: 3, lastSeq: 1, wantSeq: 3, wantSent: []int64{2, 3}},
+		{
+			name: "trimmed stream", broadcasts: 3, lastSeq: 1, wantSeq: 3, wantResync: true,
+			setup: func(t *testing.T, redisClient *redislib.Client) {
+				redisClient.XDel(context.Background(), getEventStreamKey(eventID), "2-0")
+			},
+		},
+		{
+			name: "expired stream", broadcasts: 3, lastSeq: 1, wantSeq: 3, wantResync: true,
+			setup: func(t *testing.T, redisClient *redislib.Client) {
+				redisClient.Del(context.Background(), getEventStreamKey(eventID))
+			},
+		},
+		{name: "gap larger than maxReplay", broadcasts: 4, maxReplay: 2, lastSeq: 1, wantSeq: 4, wantResync: true},
+		{name: "gap of maxReplay", broadcasts: 3, maxReplay: 2, lastSeq: 1, wantSeq: 3, wantSent: []int64{2, 3}},
+		{name: "sequence restarted", broadcasts: 2, lastSeq: 5, wantSeq: 2, wantResync: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.maxReplay > 0 {
+				defaultMaxReplay := maxReplay
+				maxReplay = tt.maxReplay
+				defer func() { maxReplay = defaultMaxReplay }()
+			}
+
+			redisClient := redislib.NewClient(&redislib.Options{Addr: miniredis.RunT(t).Addr()})
+			cm := &ConnectionManager{redisClient: redisClient}
+
+			for i := 0; i < tt.broadcasts; i++ {
+				broadcastMsgs := dto.BroadcastMsgs{Messages: []dto.BroadcastMsg{{EventID: eventID, SectionID: 2, RowID: 3, Price: 100, MaxLength: i}}}
+				if err := cm.appendBroadcast(eventID, broadcastMsgs); err != nil {
+					t.Fatalf("appendBroadcast() error = %v", err)
+				}
+			}
+			if tt.setup != nil {
+				tt.setup(t, redisClient)
+			}
+
+			// No writer: what Replay sends stays queued
+			client := &Client{
+				sessionID:     "session-1",
+				subscriptions: make(map[int]map[int]bool),
+				send:          make(chan []byte, sendBufferSize),
+				done:          make(chan struct{}),
+			}
+			client.subscribe(eventID, nil)
+
+			seq, resync, err := cm.Replay(context.Background(), client, eventID, tt.lastSeq)
+			if err != nil {
+				t.Fatalf("Replay() error = %v", err)
+			}
+			if seq != tt.wantSeq || resync != tt.wantResync {
+				t.Errorf("Replay() = (%d, %v), want (%d, %v)", seq, resync, tt.wantSeq, tt.wantResync)
+			}
+
+			var gotSent []int64
+			for len(client.send) > 0 {
+				var envelope dto.Envelope
+				if err := json.Unmarshal(<-client.send, &envelope); err != nil {
+					t.Fatalf("failed to decode message: %v", err)
+				}
+				var broadcastMsgs dto.BroadcastMsgs
+				if err := json.Unmarshal(envelope.Payload, &broadcastMsgs); err != nil {
+					t.Fatalf("failed to decode broadcast: %v", err)
+				}
+				gotSent = append(gotSent, broadcastMsgs.Seq)
+			}
+			if !reflect.DeepEqual(gotSent, tt.wantSent) {
+				t.Errorf("replayed = %v, want %v", gotSent, tt.wantSent)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	websocketlib "github.com/gorilla/websocket"
+)
+
+// transport writes the messages of a Client to the peer, over a WebSocket or a Server-Sent Events stream.
+// It is only used by the writer goroutine of the client.
+type transport interface {
+	writeMessage(data []byte) error
+	writePing() error
+	close() error
+}
+
+type wsTransport struct {
+	conn *websocketlib.Conn
+}
+
+// newWSTransport arms the heartbeat: the peer has to answer the server pings within pongWait or the next read fails
+func newWSTransport(conn *websocketlib.Conn) *wsTransport {
+	conn.SetReadLimit(maxMessageSize)
+	conn.SetReadDeadline(time.Now().Add(pongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+	return &wsTransport{conn: conn}
+}
+
+func (t *wsTransport) writeMessage(data []byte) error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocketlib.TextMessage, data)
+}
+
+func (t *wsTransport) writePing() error {
+	t.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return t.conn.WriteMessage(websocketlib.PingMessage, nil)
+}
+
+func (t *wsTransport) close() error {
+	return t.conn.Close()
+}
+
+// sseTransport writes each message as a text/event-stream event, its data is the envelope
+type sseTransport struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+}
+
+func newSSETransport(w http.ResponseWriter) *sseTransport {
+	return &sseTransport{w: w, controller: http.NewResponseController(w)}
+}
+
+func (t *sseTransport) writeMessage(data []byte) error {
+	return t.write("data: %s\n\n", data)
+}
+
+// writePing writes a comment, ignored by EventSource, which keeps proxies from closing an idle stream
+func (t *sseTransport) writePing() error {
+	return t.write(": ping\n\n")
+}
+
+// close does nothing, the stream ends when its handler returns
+func (t *sseTransport) close() error {
+	return nil
+}
+
+func (t *sseTransport) write(format string, args ...interface{}) error {
+	t.controller.SetWriteDeadline(time.Now().Add(writeWait))
+	if _, err := fmt.Fprintf(t.w, format, args...); err != nil {
+		return err
+	}
+	return t.controller.Flush()
+}
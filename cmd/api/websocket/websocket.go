@@ -2,13 +2,15 @@ package websocket
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"ticket-booking-backend/dto"
-
-	// "ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/tool/util"
+	"time"
 
 	websocketlib "github.com/gorilla/websocket"
 	redislib "github.com/redis/go-redis/v9"
@@ -17,96 +19,192 @@ import (
 type ConnectionManager struct {
 	redisClient     *redislib.Client
 	upgrader        *websocketlib.Upgrader
-	activeConns     map[string]*websocketlib.Conn // Map
-	activeConnsLock sync.RWMutex                  // Protects concurrent access to the map
+	activeConns     map[string]map[*Client]struct{} // Session ID to its connections, one per tab or device
+	activeConnsLock sync.RWMutex                    // Protects concurrent access to the map
+	stats           connectionStats
+	aggregator      *broadcastAggregator // nil when broadcasts are not coalesced
+}
+
+// connectionStats are the counters behind Stats, for monitoring
+type connectionStats struct {
+	opened atomic.Int64
+	closed atomic.Int64
+}
+
+// maxConnectionsPerSession bounds the tabs a single session can keep open
+const maxConnectionsPerSession = 10
+
+var ErrTooManyConnections = errors.New("too many connections")
+
+type Stats struct {
+	ActiveConnections int   `json:"active_connections"`
+	ActiveSessions    int   `json:"active_sessions"`
+	OpenedTotal       int64 `json:"opened_total"`
+	ClosedTotal       int64 `json:"closed_total"`
 }
 
-func NewConnectionManager(redisClient *redislib.Client) *ConnectionManager {
-	return &ConnectionManager{
+func NewConnectionManager(redisClient *redislib.Client, allowedOrigins []string) *ConnectionManager {
+	origins := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		origins[origin] = true
+	}
+
+	cm := &ConnectionManager{
 		redisClient: redisClient,
 		upgrader: &websocketlib.Upgrader{
+			// Browsers always send Origin, so pages of other sites can't open sockets with our users' cookies.
+			// Clients that are not browsers send none.
 			CheckOrigin: func(r *http.Request) bool {
-				return true // Todo: Add security checks here
+				origin := r.Header.Get("Origin")
+				return origin == "" || origins[origin]
 			},
 		},
-		activeConns: make(map[string]*websocketlib.Conn),
+		activeConns: make(map[string]map[*Client]struct{}),
 	}
+
+	// Availability updates of an event are coalesced over this window, 0 sends each update right away
+	window := time.Duration(util.GetEnvIntOrDefault("BROADCAST_WINDOW_MS", 150)) * time.Millisecond
+	if window > 0 {
+		cm.aggregator = newBroadcastAggregator(window, cm.appendBroadcast)
+	}
+	return cm
+}
+
+func (cm *ConnectionManager) AddConnection(sessionID string, wsConn *websocketlib.Conn) (*Client, error) {
+	return cm.addClient(sessionID, newWSTransport(wsConn))
+}
+
+// AddStream registers a Server-Sent Events stream, it receives the same messages as a WebSocket connection.
+// The response headers have to be written already.
+func (cm *ConnectionManager) AddStream(sessionID string, w http.ResponseWriter) (*Client, error) {
+	return cm.addClient(sessionID, newSSETransport(w))
 }
 
-func (cm *ConnectionManager) AddConnection(sessionID string, wsConn *websocketlib.Conn) error {
+func (cm *ConnectionManager) addClient(sessionID string, transport transport) (*Client, error) {
 	cm.activeConnsLock.Lock()
 	defer cm.activeConnsLock.Unlock()
 
-	if _, exists := cm.activeConns[sessionID]; exists {
-		return fmt.Errorf("connection already exists for session ID: %s", sessionID)
+	clients, exists := cm.activeConns[sessionID]
+	if !exists {
+		clients = make(map[*Client]struct{})
+		cm.activeConns[sessionID] = clients
+	}
+	if len(clients) >= maxConnectionsPerSession {
+		return nil, fmt.Errorf("%w for session ID: %s", ErrTooManyConnections, sessionID)
 	}
 
-	cm.activeConns[sessionID] = wsConn
-	return nil
+	client := newClient(sessionID, transport, cm.removeClient)
+	clients[client] = struct{}{}
+	cm.stats.opened.Add(1)
+	return client, nil
 }
 
 func (cm *ConnectionManager) CreateConnection(w http.ResponseWriter, r *http.Request, h http.Header) (*websocketlib.Conn, error) {
 	return cm.upgrader.Upgrade(w, r, h)
 }
 
-func (cm *ConnectionManager) RemoveConnection(sessionID string) {
+// RemoveConnection closes a single connection, the other connections of the session are left open.
+// A reconnect racing the cleanup of the old socket therefore keeps its new connection.
+func (cm *ConnectionManager) RemoveConnection(client *Client) {
+	client.Close() // unregisters through removeClient
+}
+
+// removeClient is called once when a client closes, for any reason (read error, write failure, slow consumer)
+func (cm *ConnectionManager) removeClient(client *Client) {
 	cm.activeConnsLock.Lock()
 	defer cm.activeConnsLock.Unlock()
 
-	if conn, exists := cm.activeConns[sessionID]; exists {
-		conn.Close()
-		delete(cm.activeConns, sessionID)
+	if clients, exists := cm.activeConns[client.sessionID]; exists {
+		delete(clients, client)
+		if len(clients) == 0 {
+			delete(cm.activeConns, client.sessionID)
+		}
 	}
+	cm.stats.closed.Add(1)
 }
 
-func (cm *ConnectionManager) GetConnectionInfo(sessionID string) (*websocketlib.Conn, error) {
+func (cm *ConnectionManager) Stats() Stats {
 	cm.activeConnsLock.RLock()
-	defer cm.activeConnsLock.RUnlock()
+	activeSessions := len(cm.activeConns)
+	activeConnections := 0
+	for _, clients := range cm.activeConns {
+		activeConnections += len(clients)
+	}
+	cm.activeConnsLock.RUnlock()
 
-	conn, exists := cm.activeConns[sessionID]
-	if !exists {
-		return nil, fmt.Errorf("no active connection found for session ID: %s", sessionID)
+	return Stats{
+		ActiveConnections: activeConnections,
+		ActiveSessions:    activeSessions,
+		OpenedTotal:       cm.stats.opened.Load(),
+		ClosedTotal:       cm.stats.closed.Load(),
 	}
-	return conn, nil
 }
 
-func (cm *ConnectionManager) GetAllConnections() map[string]*websocketlib.Conn {
+// GetConnections returns the connections of the session held by this instance
+func (cm *ConnectionManager) GetConnections(sessionID string) []*Client {
 	cm.activeConnsLock.RLock()
 	defer cm.activeConnsLock.RUnlock()
 
-	// Return a copy of the active connections map
-	connectionsCopy := make(map[string]*websocketlib.Conn)
-	for sessionID, conn := range cm.activeConns {
-		connectionsCopy[sessionID] = conn
+	clients := make([]*Client, 0, len(cm.activeConns[sessionID]))
+	for client := range cm.activeConns[sessionID] {
+		clients = append(clients, client)
 	}
-	return connectionsCopy
+	return clients
 }
 
-func (cm *ConnectionManager) BroadcastReservation(data []byte) error {
-	// var broadcastMsg dto.BroadcastMsg
-	// if err := json.Unmarshal(data, &broadcastMsg); err != nil {
-	// 	return fmt.Errorf("failed to unmarshal msg, error", err)
-	// }
+func (cm *ConnectionManager) GetAllConnections() []*Client {
+	cm.activeConnsLock.RLock()
+	defer cm.activeConnsLock.RUnlock()
+
+	// Return a copy so the connections can be written to without holding the lock
+	var clients []*Client
+	for _, sessionClients := range cm.activeConns {
+		for client := range sessionClients {
+			clients = append(clients, client)
+		}
+	}
+	return clients
+}
 
-	connections := cm.GetAllConnections()
+// Subscribe makes the client receive broadcasts of the event, limited to sectionIDs if any
+func (cm *ConnectionManager) Subscribe(client *Client, eventID int, sectionIDs []int) {
+	client.subscribe(eventID, sectionIDs)
+}
 
-	var wg sync.WaitGroup
-	for _, conn := range connections {
-		wg.Add(1)
+func (cm *ConnectionManager) Unsubscribe(client *Client, eventID int) {
+	client.unsubscribe(eventID)
+}
 
-		go func(c *websocketlib.Conn) {
-			defer wg.Done()
+// BroadcastReservation sends data to every connection of every API instance.
+// The messages of each event are numbered, see Replay.
+// With a broadcast window, the messages are only queued: they go out with the other updates of the window.
+func (cm *ConnectionManager) BroadcastReservation(data []byte) error {
+	var broadcastMsgs dto.BroadcastMsgs
+	if err := json.Unmarshal(data, &broadcastMsgs); err != nil {
+		return fmt.Errorf("failed to unmarshal broadcast msgs:%+w", err)
+	}
 
-			err := c.WriteMessage(websocketlib.TextMessage, data)
-			if err != nil {
-				log.Printf("Failed to send message to connection: %v", err)
-				// Todo: handle reconnection or cleanup
-			}
-		}(conn)
+	if cm.aggregator != nil {
+		cm.aggregator.add(broadcastMsgs.Messages)
+		return nil
 	}
 
-	wg.Wait()
+	var eventIDs []int
+	byEvent := make(map[int]dto.BroadcastMsgs)
+	for _, msg := range broadcastMsgs.Messages {
+		eventMsgs, exists := byEvent[msg.EventID]
+		if !exists {
+			eventIDs = append(eventIDs, msg.EventID)
+		}
+		eventMsgs.Messages = append(eventMsgs.Messages, msg)
+		byEvent[msg.EventID] = eventMsgs
+	}
 
+	for _, eventID := range eventIDs {
+		if err := cm.appendBroadcast(eventID, byEvent[eventID]); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -116,19 +214,63 @@ func (cm *ConnectionManager) NotifyReservation(data []byte) error {
 		return fmt.Errorf("failed to unmarshal msg:%+w", err)
 	}
 
-	sessionID := notificationMsg.SessionID
+	return cm.NotifySession(notificationMsg.SessionID, dto.TypeReservationSucceeded, data)
+}
 
-	conn, err := cm.GetConnectionInfo(sessionID)
+// NotifySession sends a message to every connection of a single session, whichever API instances hold them.
+// payload is the JSON of the message, it is wrapped in an envelope of type msgType.
+func (cm *ConnectionManager) NotifySession(sessionID, msgType string, payload []byte) error {
+	envelope, err := json.Marshal(dto.Envelope{
+		Version: dto.WSProtocolVersion,
+		Type:    msgType,
+		Payload: payload,
+	})
 	if err != nil {
-		return fmt.Errorf("failed to get connection info:%+w", err)
+		return fmt.Errorf("failed to marshal envelope:%+w", err)
 	}
 
-	err = conn.WriteMessage(websocketlib.TextMessage, data)
+	msg, err := json.Marshal(sessionMessage{
+		SessionID: sessionID,
+		Data:      envelope,
+	})
 	if err != nil {
-		log.Printf("Failed to send message to connection: %v", err)
-		// Todo: handle reconnection or cleanup
-		return err
+		return fmt.Errorf("failed to marshal session message:%+w", err)
 	}
 
-	return nil
+	return cm.publish(notifyChannel, msg)
+}
+
+// broadcastLocal writes to the connections held by this instance the messages
+// of the events and sections they subscribed to
+func (cm *ConnectionManager) broadcastLocal(data []byte) {
+	var broadcast sequencedBroadcast
+	if err := json.Unmarshal(data, &broadcast); err != nil {
+		log.Printf("Failed to unmarshal broadcast: %v", err)
+		return
+	}
+
+	var broadcastMsgs dto.BroadcastMsgs
+	if err := json.Unmarshal(broadcast.Data, &broadcastMsgs); err != nil {
+		log.Printf("Failed to unmarshal broadcast msgs: %v", err)
+		return
+	}
+	broadcastMsgs.Seq = broadcast.Seq
+
+	for _, client := range cm.GetAllConnections() {
+		if err := client.sendBroadcast(broadcastMsgs); err != nil {
+			log.Printf("Failed to send message to session %s: %v", client.SessionID(), err)
+			cm.RemoveConnection(client) // do not keep writing to a dead or stalled connection
+		}
+	}
+}
+
+// notifyLocal writes data to the session's connections held by this instance,
+// none when the session is connected to another instance or not connected
+func (cm *ConnectionManager) notifyLocal(sessionID string, data []byte) {
+	for _, client := range cm.GetConnections(sessionID) {
+		if err := client.Send(data); err != nil {
+			log.Printf("Failed to send message to session %s: %v", sessionID, err)
+			cm.RemoveConnection(client)
+		}
+	}
 }
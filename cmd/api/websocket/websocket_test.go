@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeTransport fails every write with err, or blocks every write until closed when block is set
+type fakeTransport struct {
+	err       error
+	block     bool
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newFakeTransport(err error, block bool) *fakeTransport {
+	return &fakeTransport{err: err, block: block, closed: make(chan struct{})}
+}
+
+func (t *fakeTransport) writeMessage(data []byte) error {
+	if t.block {
+		<-t.closed
+		return errors.New("closed")
+	}
+	return t.err
+}
+
+func (t *fakeTransport) writePing() error { return t.err }
+
+func (t *fakeTransport) close() error {
+	t.closeOnce.Do(func() { close(t.closed) })
+	return nil
+}
+
+func TestNotifyLocalRemovesDeadConnections(t *testing.T) {
+	tests := []struct {
+		name        string
+		transport   *fakeTransport
+		messages    int
+		wantRemoved bool
+	}{
+		{name: "healthy connection stays registered", transport: newFakeTransport(nil, false), messages: 3},
+		{name: "write failure unregisters the connection", transport: newFakeTransport(errors.New("broken pipe"), false), messages: 1, wantRemoved: true},
+		{name: "full queue unregisters the connection", transport: newFakeTransport(nil, true), messages: sendBufferSize + 2, wantRemoved: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cm := &ConnectionManager{activeConns: make(map[string]map[*Client]struct{})}
+			client, err := cm.addClient("session-1", tt.transport)
+			if err != nil {
+				t.Fatalf("addClient() error = %v", err)
+			}
+			defer client.Close()
+
+			for i := 0; i < tt.messages; i++ {
+				cm.notifyLocal("session-1", []byte(`{"type":"test"}`))
+			}
+
+			if tt.wantRemoved {
+				select {
+				case <-client.Done():
+				case <-time.After(time.Second):
+					t.Fatal("client was not closed")
+				}
+			}
+
+			if removed := len(cm.GetConnections("session-1")) == 0; removed != tt.wantRemoved {
+				t.Errorf("connection removed = %v, want %v", removed, tt.wantRemoved)
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package booking
+
+import "time"
+
+type Booking struct {
+	ID          int       `db:"id"`
+	CreatedAt   time.Time `db:"created_at"`
+	EventSeatID int       `db:"event_seat_id"`
+	BookedBy    int       `db:"booked_by"`
+}
+
+// EventSeat is a seat priced for an event, the unit a booking refers to
+type EventSeat struct {
+	EventSeatID int `db:"event_seat_id"`
+	SeatID      int `db:"seat_id"`
+	SeatNumber  int `db:"seat_number"`
+	Price       int `db:"price"`
+}
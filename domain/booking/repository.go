@@ -0,0 +1,89 @@
+package booking
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+type BookingRepository struct {
+	db *sql.DB
+}
+
+func NewBookingRepository(db *sql.DB) *BookingRepository {
+	return &BookingRepository{db: db}
+}
+
+// GetEventSeats returns the event seats of a row between startSeatNumber and startSeatNumber+length-1
+func (repo *BookingRepository) GetEventSeats(eventID, rowID, startSeatNumber, length int) ([]EventSeat, error) {
+	query := `
+		SELECT 
+			event_seat.id AS event_seat_id,
+			seats.id AS seat_id,
+			seats.seat_number,
+			event_seat.price
+		FROM seats
+		JOIN event_seat ON event_seat.seat_id = seats.id
+		WHERE seats.row_id = $1
+		AND event_seat.event_id = $2
+		AND seats.seat_number BETWEEN $3 AND $4
+		ORDER BY seats.seat_number
+	`
+
+	rows, err := repo.db.Query(query, rowID, eventID, startSeatNumber, startSeatNumber+length-1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event seats: %w", err)
+	}
+	defer rows.Close()
+
+	eventSeats := []EventSeat{}
+	for rows.Next() {
+		var eventSeat EventSeat
+		if err := rows.Scan(&eventSeat.EventSeatID, &eventSeat.SeatID, &eventSeat.SeatNumber, &eventSeat.Price); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		eventSeats = append(eventSeats, eventSeat)
+	}
+
+	if len(eventSeats) != length {
+		return nil, fmt.Errorf("expected %d event seats in row %d, found %d", length, rowID, len(eventSeats))
+	}
+
+	return eventSeats, nil
+}
+
+func (repo *BookingRepository) CreateBookings(eventSeatIDs []int, userID int) error {
+	tx, err := repo.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+
+	// Refuse the whole batch if any seat is already booked
+	var bookedCount int
+	checkQuery := "SELECT COUNT(*) FROM bookings WHERE event_seat_id = ANY($1)"
+	if err := tx.QueryRow(checkQuery, pq.Array(eventSeatIDs)).Scan(&bookedCount); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to check existing bookings: %w", err)
+	}
+	if bookedCount > 0 {
+		tx.Rollback()
+		return ErrSeatAlreadyBooked
+	}
+
+	now := time.Now()
+	for _, eventSeatID := range eventSeatIDs {
+		query := "INSERT INTO bookings (created_at, event_seat_id, booked_by) VALUES ($1, $2, $3)"
+		if _, err := tx.Exec(query, now, eventSeatID, userID); err != nil {
+			tx.Rollback()
+			// unique_booking_event_seat catches a concurrent booking of the same seat
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				return ErrSeatAlreadyBooked
+			}
+			return fmt.Errorf("failed to insert booking: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
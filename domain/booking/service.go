@@ -0,0 +1,31 @@
+package booking
+
+import (
+	"database/sql"
+	"errors"
+)
+
+// ErrSeatAlreadyBooked is returned when one of the seats already has a bookings row
+var ErrSeatAlreadyBooked = errors.New("seat already booked")
+
+type BookingService struct {
+	repo *BookingRepository
+}
+
+func NewBookingService(db *sql.DB) *BookingService {
+	return &BookingService{
+		repo: NewBookingRepository(db),
+	}
+}
+
+func (s *BookingService) GetEventSeats(eventID, rowID, startSeatNumber, length int) ([]EventSeat, error) {
+	return s.repo.GetEventSeats(eventID, rowID, startSeatNumber, length)
+}
+
+func (s *BookingService) CreateBookings(eventSeats []EventSeat, userID int) error {
+	eventSeatIDs := make([]int, 0, len(eventSeats))
+	for _, eventSeat := range eventSeats {
+		eventSeatIDs = append(eventSeatIDs, eventSeat.EventSeatID)
+	}
+	return s.repo.CreateBookings(eventSeatIDs, userID)
+}
@@ -0,0 +1,42 @@
+package payment
+
+import (
+	"fmt"
+	"log"
+	"ticket-booking-backend/domain/ticket"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Checkout charges every hold of the session for the event at once, then books them in a single transaction.
+// The charge is refunded when the seats cannot be booked.
+func (s *PaymentService) Checkout(ctx *gin.Context, eventID int) ([]ticket.BookedHold, error) {
+	sessionIDAny, exists := ctx.Get("session_id")
+	if !exists {
+		return nil, fmt.Errorf("session ID not found in context")
+	}
+	sessionID := sessionIDAny.(string)
+
+	userID, err := s.userService.GetIDBySessionToken(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	holds, err := s.ticketService.GetHolds(ctx.Request.Context(), sessionID, eventID)
+	if err != nil {
+		return nil, err
+	}
+	if len(holds) == 0 {
+		return nil, ticket.ErrHoldNotFound
+	}
+
+	paid, err := s.payHolds(ctx.Request.Context(), sessionID, userID, eventID, holds,
+		fmt.Sprintf("event %d checkout of %d holds", eventID, len(holds)))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("event %d checked out, charge id: %s, amount: %d", eventID, paid.ChargeID, paid.Amount)
+
+	return paid.BookedHolds, nil
+}
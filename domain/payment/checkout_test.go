@@ -0,0 +1,86 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"ticket-booking-backend/domain/booking"
+	"ticket-booking-backend/domain/user"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeSessionUsers map[string]int
+
+func (f fakeSessionUsers) GetIDBySessionToken(sessionToken string) (int, error) {
+	userID, exists := f[sessionToken]
+	if !exists {
+		return 0, user.ErrNotLoggedIn
+	}
+	return userID, nil
+}
+
+func TestCheckout(t *testing.T) {
+	tests := []struct {
+		name        string
+		loggedIn    bool
+		decline     bool
+		bookingErr  error
+		wantErr     error
+		wantBooked  int
+		wantCharged int
+		wantHold    bool
+	}{
+		{name: "holds are charged and booked", loggedIn: true, wantBooked: 3, wantCharged: 3 * testPrice},
+		{name: "anonymous session is refused", wantErr: user.ErrNotLoggedIn, wantHold: true},
+		{name: "declined payment books nothing", loggedIn: true, decline: true, wantErr: ErrPaymentDeclined, wantHold: true},
+		{name: "failed booking refunds the charge", loggedIn: true, bookingErr: booking.ErrSeatAlreadyBooked, wantErr: booking.ErrSeatAlreadyBooked, wantHold: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticketService, _ := newTestTicketService(t)
+			hold := reserveTestHold(t, ticketService, 3)
+
+			users := fakeSessionUsers{}
+			if tt.loggedIn {
+				users[testSessionID] = testUserID
+			}
+			provider := NewFakeProvider()
+			provider.Decline = tt.decline
+			store := &fakeBookingStore{seatPrice: testPrice, err: tt.bookingErr}
+			s := &PaymentService{provider: provider, ticketService: ticketService, bookingService: store, userService: users}
+
+			ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+			ctx.Request = httptest.NewRequest(http.MethodPost, "/events/1/checkout", nil)
+			ctx.Set("session_id", testSessionID)
+
+			bookedHolds, err := s.Checkout(ctx, testEventID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Checkout() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && (len(bookedHolds) != 1 || bookedHolds[0].HoldID != hold.ID) {
+				t.Errorf("booked holds = %+v, want hold %s", bookedHolds, hold.ID)
+			}
+
+			if len(store.booked) != tt.wantBooked {
+				t.Errorf("booked %d seats, want %d", len(store.booked), tt.wantBooked)
+			}
+
+			charged := 0
+			for _, charge := range provider.Charges() {
+				charged += charge.Amount
+			}
+			if charged != tt.wantCharged {
+				t.Errorf("charged %d, want %d", charged, tt.wantCharged)
+			}
+
+			_, err = ticketService.GetHold(context.Background(), testSessionID, hold.ID)
+			if gotHold := err == nil; gotHold != tt.wantHold {
+				t.Errorf("hold still there = %v, want %v (err: %v)", gotHold, tt.wantHold, err)
+			}
+		})
+	}
+}
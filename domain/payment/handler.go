@@ -1 +1,103 @@
 package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"ticket-booking-backend/domain/booking"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/dto"
+	"time"
+)
+
+// HandlePaymentMessage consumes the "pay" queue: it pays the hold with payHolds,
+// the same way as the checkout, and tells the session the outcome.
+func (s *PaymentService) HandlePaymentMessage(data []byte) error {
+	var msg dto.PaymentMsg
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return fmt.Errorf("failed to unmarshal msg, error: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	paid, err := s.payHold(ctx, msg)
+	if err != nil {
+		log.Printf("payment of hold %s failed for session %s: %v", msg.HoldID, msg.SessionID, err)
+
+		if notifyErr := s.notifyPayment(msg, 0, err); notifyErr != nil {
+			log.Printf("failed to notify WebSocket client: %v", notifyErr)
+		}
+
+		// Declined or lost holds are a valid outcome, only unexpected failures are nacked.
+		// Replaying them could charge a card already declined.
+		if isRejectedPaymentErr(err) {
+			return nil
+		}
+		return err
+	}
+
+	log.Printf("hold %s paid, charge id: %s, amount: %d", msg.HoldID, paid.ChargeID, paid.Amount)
+
+	if err := s.notifyPayment(msg, paid.Amount, nil); err != nil {
+		log.Printf("failed to notify WebSocket client: %v", err)
+	}
+
+	return nil
+}
+
+func (s *PaymentService) payHold(ctx context.Context, msg dto.PaymentMsg) (paidHolds, error) {
+	// The hold may have expired while the message was queued
+	hold, err := s.ticketService.GetHold(ctx, msg.SessionID, msg.HoldID)
+	if err != nil {
+		return paidHolds{}, err
+	}
+
+	return s.payHolds(ctx, msg.SessionID, msg.UserID, hold.EventID, []ticket.Hold{hold},
+		fmt.Sprintf("event %d hold %s", hold.EventID, hold.ID))
+}
+
+// isRejectedPaymentErr tells the payments refused because of the card or the hold, not because of a failure.
+// ErrHoldNotFound is an expired hold, or a duplicate message of a hold already being paid.
+func isRejectedPaymentErr(err error) bool {
+	return errors.Is(err, ErrPaymentDeclined) || errors.Is(err, ticket.ErrHoldNotFound) ||
+		errors.Is(err, booking.ErrSeatAlreadyBooked)
+}
+
+// notifyPayment sends the outcome of the payment to the session, amount is the amount charged on success
+func (s *PaymentService) notifyPayment(msg dto.PaymentMsg, amount int, paymentErr error) error {
+	resultMsg := dto.PaymentResultMsg{
+		EventID:   msg.EventID,
+		HoldID:    msg.HoldID,
+		Amount:    amount,
+		SessionID: msg.SessionID,
+	}
+	msgType := dto.TypePaymentSucceeded
+	if paymentErr != nil {
+		msgType = dto.TypePaymentFailed
+		resultMsg.Reason, resultMsg.Message = getFailureDetails(paymentErr)
+	}
+
+	data, err := json.Marshal(resultMsg)
+	if err != nil {
+		return fmt.Errorf("error marshaling payment message: %w", err)
+	}
+
+	return s.notifier.NotifySession(msg.SessionID, msgType, data)
+}
+
+// getFailureDetails returns the reason code and the message shown to the client
+func getFailureDetails(err error) (string, string) {
+	switch {
+	case errors.Is(err, ErrPaymentDeclined):
+		return dto.ReasonPaymentDeclined, ErrPaymentDeclined.Error()
+	case errors.Is(err, ticket.ErrHoldNotFound):
+		return dto.ReasonHoldNotFound, ticket.ErrHoldNotFound.Error()
+	case errors.Is(err, booking.ErrSeatAlreadyBooked):
+		return dto.ReasonSeatAlreadyBooked, booking.ErrSeatAlreadyBooked.Error()
+	default:
+		return dto.ReasonInternalError, "failed to process payment" // do not leak internals to the client
+	}
+}
@@ -0,0 +1,215 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/domain/booking"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/dto"
+
+	"github.com/alicebob/miniredis/v2"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+// fakeBookingStore prices every seat at seatPrice and records the bookings in memory
+type fakeBookingStore struct {
+	seatPrice int
+	err       error // returned by CreateBookings
+	booked    []booking.EventSeat
+	bookedBy  int
+}
+
+func (f *fakeBookingStore) GetEventSeats(eventID, rowID, startSeatNumber, length int) ([]booking.EventSeat, error) {
+	eventSeats := make([]booking.EventSeat, 0, length)
+	for seatNumber := startSeatNumber; seatNumber < startSeatNumber+length; seatNumber++ {
+		eventSeats = append(eventSeats, booking.EventSeat{
+			EventSeatID: rowID*100 + seatNumber,
+			SeatID:      rowID*100 + seatNumber,
+			SeatNumber:  seatNumber,
+			Price:       f.seatPrice,
+		})
+	}
+	return eventSeats, nil
+}
+
+func (f *fakeBookingStore) CreateBookings(eventSeats []booking.EventSeat, userID int) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.booked = append(f.booked, eventSeats...)
+	f.bookedBy = userID
+	return nil
+}
+
+// fakeNotifier records the messages sent to sessions
+type fakeNotifier struct {
+	types    []string
+	payloads [][]byte
+}
+
+func (f *fakeNotifier) NotifySession(sessionID, msgType string, payload []byte) error {
+	f.types = append(f.types, msgType)
+	f.payloads = append(f.payloads, payload)
+	return nil
+}
+
+const (
+	testEventID   = 1
+	testSectionID = 2
+	testRowID     = 3
+	testPrice     = 100
+	testSessionID = "session-1"
+	testUserID    = 7
+)
+
+// newTestTicketService returns a ticket service on an in-memory redis holding one row of four seats at testPrice
+func newTestTicketService(t *testing.T) (*ticket.TicketService, *redislib.Client) {
+	t.Helper()
+	t.Setenv("BROADCAST_WINDOW_MS", "0")
+
+	mr := miniredis.RunT(t)
+	redisClient := redislib.NewClient(&redislib.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	ctx := context.Background()
+	redisClient.HSet(ctx, "event:1:section:2:rows", "3", `{"row_name":"A","seats":"0000"}`)
+	redisClient.ZAdd(ctx, "event:1:section:2:price_blocks", redislib.Z{Score: testPrice, Member: "3:301:1:304:4"})
+
+	cm := websocket.NewConnectionManager(redisClient, nil)
+	return ticket.NewTicketService(redisClient, nil, nil, nil, cm), redisClient
+}
+
+// reserveTestHold holds length seats of the row for testSessionID and returns the hold
+func reserveTestHold(t *testing.T, ticketService *ticket.TicketService, length int) ticket.Hold {
+	t.Helper()
+
+	data, _ := json.Marshal(dto.ReservationMsg{
+		RequestID: "request-1",
+		EventID:   testEventID,
+		SectionID: testSectionID,
+		RowID:     testRowID,
+		Price:     testPrice,
+		Length:    length,
+		SessionID: testSessionID,
+	})
+	if err := ticketService.HandleBookingMessage(data); err != nil {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+
+	holds, err := ticketService.GetHolds(context.Background(), testSessionID, testEventID)
+	if err != nil || len(holds) != 1 {
+		t.Fatalf("expected one hold, got %v, err: %v", holds, err)
+	}
+	return holds[0]
+}
+
+func TestHandlePaymentMessage(t *testing.T) {
+	errDBDown := errors.New("db down")
+
+	tests := []struct {
+		name        string
+		claimed     bool // already being paid by another message
+		decline     bool
+		bookingErr  error
+		wantErr     error // nacked
+		wantBooked  int
+		wantCharged int
+		wantHold    bool // the hold is still there to pay again or to expire
+		wantType    string
+		wantReason  string
+	}{
+		{name: "paid hold is booked", wantBooked: 2, wantCharged: 2 * testPrice, wantType: dto.TypePaymentSucceeded},
+		{
+			name: "hold being paid is not paid twice", claimed: true, wantHold: true,
+			wantType: dto.TypePaymentFailed, wantReason: dto.ReasonHoldNotFound,
+		},
+		{
+			name: "declined payment is acked", decline: true, wantHold: true,
+			wantType: dto.TypePaymentFailed, wantReason: dto.ReasonPaymentDeclined,
+		},
+		{
+			name: "seats booked meanwhile are refunded and acked", bookingErr: booking.ErrSeatAlreadyBooked, wantHold: true,
+			wantType: dto.TypePaymentFailed, wantReason: dto.ReasonSeatAlreadyBooked,
+		},
+		{
+			name: "unexpected failure is refunded and nacked", bookingErr: errDBDown, wantErr: errDBDown, wantHold: true,
+			wantType: dto.TypePaymentFailed, wantReason: dto.ReasonInternalError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticketService, redisClient := newTestTicketService(t)
+			hold := reserveTestHold(t, ticketService, 2)
+			if tt.claimed {
+				if _, err := ticketService.ClaimHold(context.Background(), testSessionID, hold.ID); err != nil {
+					t.Fatalf("failed to claim hold: %v", err)
+				}
+			}
+
+			provider := NewFakeProvider()
+			provider.Decline = tt.decline
+			store := &fakeBookingStore{seatPrice: testPrice, err: tt.bookingErr}
+			notifier := &fakeNotifier{}
+			s := &PaymentService{provider: provider, ticketService: ticketService, bookingService: store, notifier: notifier}
+
+			data, _ := json.Marshal(dto.PaymentMsg{EventID: testEventID, HoldID: hold.ID, UserID: testUserID, SessionID: testSessionID})
+			err := s.HandlePaymentMessage(data)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("HandlePaymentMessage() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if len(store.booked) != tt.wantBooked {
+				t.Errorf("booked %d seats, want %d", len(store.booked), tt.wantBooked)
+			}
+			if tt.wantBooked > 0 && store.bookedBy != testUserID {
+				t.Errorf("booked by user %d, want %d", store.bookedBy, testUserID)
+			}
+
+			charged := 0
+			for _, charge := range provider.Charges() {
+				charged += charge.Amount
+			}
+			if charged != tt.wantCharged {
+				t.Errorf("charged %d, want %d", charged, tt.wantCharged)
+			}
+
+			// The session is told the outcome
+			if len(notifier.types) != 1 || notifier.types[0] != tt.wantType {
+				t.Fatalf("notified %v, want %s", notifier.types, tt.wantType)
+			}
+			var result dto.PaymentResultMsg
+			if err := json.Unmarshal(notifier.payloads[0], &result); err != nil {
+				t.Fatalf("failed to decode notification: %v", err)
+			}
+			if result.HoldID != hold.ID || result.Reason != tt.wantReason || result.Amount != tt.wantCharged {
+				t.Errorf("notification = %+v, want reason %q and amount %d", result, tt.wantReason, tt.wantCharged)
+			}
+
+			_, err = ticketService.GetHold(context.Background(), testSessionID, hold.ID)
+			if gotHold := err == nil; gotHold != tt.wantHold {
+				t.Errorf("hold still there = %v, want %v (err: %v)", gotHold, tt.wantHold, err)
+			}
+
+			// An unpaid hold keeps a deadline, so the reaper still releases it
+			err = redisClient.ZScore(context.Background(), "hold_deadlines", testSessionID+"|"+hold.ID).Err()
+			if gotDeadline := err == nil; gotDeadline != tt.wantHold {
+				t.Errorf("hold deadline there = %v, want %v (err: %v)", gotDeadline, tt.wantHold, err)
+			}
+			// and is not left claimed, unless another message is paying it
+			claimed := redisClient.Exists(context.Background(), "hold_claim:"+testSessionID+"|"+hold.ID).Val() == 1
+			if claimed != tt.claimed {
+				t.Errorf("hold claimed = %v, want %v", claimed, tt.claimed)
+			}
+
+			// Paid or not, the seats stay off sale until the hold is booked or released
+			rowData, _ := redisClient.HGet(context.Background(), "event:1:section:2:rows", "3").Result()
+			if rowData != `{"row_name":"A","seats":"1100"}` {
+				t.Errorf("row data = %s", rowData)
+			}
+		})
+	}
+}
@@ -0,0 +1,8 @@
+package payment
+
+type Charge struct {
+	UserID      int
+	EventID     int
+	Amount      int
+	Description string
+}
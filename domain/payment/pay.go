@@ -0,0 +1,109 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"ticket-booking-backend/domain/booking"
+	"ticket-booking-backend/domain/ticket"
+	"time"
+)
+
+// payTimeout bounds a payment, it must end well within ticket.HoldClaimTTL
+// or the reaper may release the seats being booked
+var payTimeout = 30 * time.Second
+
+// paidHolds is the outcome of a successful payHolds
+type paidHolds struct {
+	ChargeID    string
+	Amount      int
+	BookedHolds []ticket.BookedHold
+}
+
+// payHolds is the one way holds are paid, by the checkout and by the pay queue worker:
+// it claims the holds, charges their seats in a single charge, books them for the user and drops the holds.
+// The charge is refunded when the seats cannot be booked, and holds that are not booked get their deadline back.
+func (s *PaymentService) payHolds(ctx context.Context, sessionID string, userID, eventID int,
+	holds []ticket.Hold, description string) (paidHolds, error) {
+	ctx, cancel := context.WithTimeout(ctx, payTimeout)
+	defer cancel()
+
+	// From now on the reaper and cancellations leave the seats alone
+	var claims []ticket.HoldClaim
+	booked := false
+	defer func() {
+		if !booked {
+			s.restoreHolds(claims)
+		}
+	}()
+
+	for _, hold := range holds {
+		claim, err := s.ticketService.ClaimHold(ctx, sessionID, hold.ID)
+		if err != nil {
+			return paidHolds{}, err
+		}
+		claims = append(claims, claim)
+	}
+
+	// Price the seats from the DB, not from anything the client sent
+	paid := paidHolds{BookedHolds: make([]ticket.BookedHold, 0, len(holds))}
+	var eventSeats []booking.EventSeat
+	for _, hold := range holds {
+		holdSeats, err := s.bookingService.GetEventSeats(hold.EventID, hold.RowID, hold.StartSeatNumber, hold.Length)
+		if err != nil {
+			return paidHolds{}, err
+		}
+		eventSeats = append(eventSeats, holdSeats...)
+
+		bookedHold := ticket.BookedHold{
+			HoldID:    hold.ID,
+			SectionID: hold.SectionID,
+			RowID:     hold.RowID,
+		}
+		for _, eventSeat := range holdSeats {
+			bookedHold.SeatNumbers = append(bookedHold.SeatNumbers, eventSeat.SeatNumber)
+			paid.Amount += eventSeat.Price
+		}
+		paid.BookedHolds = append(paid.BookedHolds, bookedHold)
+	}
+
+	chargeID, err := s.provider.Charge(ctx, Charge{
+		UserID:      userID,
+		EventID:     eventID,
+		Amount:      paid.Amount,
+		Description: description,
+	})
+	if err != nil {
+		return paidHolds{}, fmt.Errorf("failed to charge %s: %w", description, err)
+	}
+	paid.ChargeID = chargeID
+
+	if err := s.bookingService.CreateBookings(eventSeats, userID); err != nil {
+		if refundErr := s.provider.Refund(ctx, chargeID); refundErr != nil {
+			log.Printf("failed to refund charge %s: %v", chargeID, refundErr)
+		}
+		return paidHolds{}, err
+	}
+	booked = true
+
+	// Seats stay marked as taken in Redis, only the holds themselves go away
+	for _, hold := range holds {
+		if err := s.ticketService.RemoveHold(ctx, sessionID, hold.ID); err != nil {
+			log.Printf("failed to remove paid hold %s: %v", hold.ID, err)
+		}
+	}
+
+	return paid, nil
+}
+
+// restoreHolds gives back the deadlines of claimed holds that were not booked
+func (s *PaymentService) restoreHolds(claims []ticket.HoldClaim) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	for _, claim := range claims {
+		if err := s.ticketService.RestoreHold(ctx, claim); err != nil {
+			log.Printf("failed to restore hold %s: %v", claim.HoldID, err)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package payment
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/dto"
+	"time"
+
+	redislib "github.com/redis/go-redis/v9"
+)
+
+func TestPayHolds(t *testing.T) {
+	tests := []struct {
+		name        string
+		claimSecond bool // the second hold is being paid by another message
+		expireFirst bool // the first hold is past its deadline, not released yet
+		wantErr     error
+		wantBooked  int
+	}{
+		{name: "every hold is paid at once", wantBooked: 3},
+		{name: "one hold being paid pays none", claimSecond: true, wantErr: ticket.ErrHoldNotFound},
+		{name: "one expired hold pays none", expireFirst: true, wantErr: ticket.ErrHoldNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ticketService, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			first := reserveTestHold(t, ticketService, 2)
+			if err := ticketService.HandleBookingMessage(mustMarshal(t, dto.ReservationMsg{
+				RequestID: "request-2", EventID: testEventID, SectionID: testSectionID, RowID: testRowID,
+				Price: testPrice, Length: 1, SessionID: testSessionID,
+			})); err != nil {
+				t.Fatalf("failed to reserve: %v", err)
+			}
+			holds, err := ticketService.GetHolds(ctx, testSessionID, testEventID)
+			if err != nil || len(holds) != 2 {
+				t.Fatalf("expected two holds, got %v, err: %v", holds, err)
+			}
+
+			if tt.claimSecond {
+				if _, err := ticketService.ClaimHold(ctx, testSessionID, holds[1].ID); err != nil {
+					t.Fatalf("failed to claim hold: %v", err)
+				}
+			}
+			if tt.expireFirst {
+				redisClient.ZAddXX(ctx, "hold_deadlines", redislib.Z{Score: float64(time.Now().Add(-time.Hour).Unix()), Member: testSessionID + "|" + first.ID})
+			}
+
+			provider := NewFakeProvider()
+			store := &fakeBookingStore{seatPrice: testPrice}
+			s := &PaymentService{provider: provider, ticketService: ticketService, bookingService: store}
+
+			paid, err := s.payHolds(ctx, testSessionID, testUserID, testEventID, holds, "test")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("payHolds() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(store.booked) != tt.wantBooked {
+				t.Errorf("booked %d seats, want %d", len(store.booked), tt.wantBooked)
+			}
+			if err != nil && len(provider.Charges()) != 0 {
+				t.Errorf("%d charges, want none", len(provider.Charges()))
+			}
+			if err == nil {
+				if len(provider.Charges()) != 1 {
+					t.Errorf("%d charges, want a single one", len(provider.Charges()))
+				}
+				if paid.Amount != tt.wantBooked*testPrice || len(paid.BookedHolds) != 2 {
+					t.Errorf("paid = %+v", paid)
+				}
+				return
+			}
+
+			// Holds claimed by this payment are given back, the other one stays claimed
+			for i, hold := range holds {
+				claimed := redisClient.Exists(ctx, "hold_claim:"+testSessionID+"|"+hold.ID).Val() == 1
+				if want := tt.claimSecond && i == 1; claimed != want {
+					t.Errorf("hold %s claimed = %v, want %v", hold.ID, claimed, want)
+				}
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("failed to marshal: %v", err)
+	}
+	return data
+}
@@ -0,0 +1,69 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// ErrPaymentDeclined is returned by a Provider when the charge is refused
+var ErrPaymentDeclined = errors.New("payment declined")
+
+// Provider charges users through an external payment gateway
+type Provider interface {
+	Charge(ctx context.Context, charge Charge) (string, error) // returns the charge ID
+	Refund(ctx context.Context, chargeID string) error
+}
+
+// FakeProvider is an in-process Provider that accepts every charge unless Decline is set.
+// Used for local development and tests.
+type FakeProvider struct {
+	mu      sync.Mutex
+	Decline bool
+	charges map[string]Charge
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{
+		charges: make(map[string]Charge),
+	}
+}
+
+func (p *FakeProvider) Charge(ctx context.Context, charge Charge) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Decline {
+		return "", ErrPaymentDeclined
+	}
+
+	chargeID := uuid.NewString()
+	p.charges[chargeID] = charge
+	return chargeID, nil
+}
+
+func (p *FakeProvider) Refund(ctx context.Context, chargeID string) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, exists := p.charges[chargeID]; !exists {
+		return fmt.Errorf("charge %s not found", chargeID)
+	}
+	delete(p.charges, chargeID)
+	return nil
+}
+
+// Charges returns a copy of the charges that have not been refunded
+func (p *FakeProvider) Charges() map[string]Charge {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	chargesCopy := make(map[string]Charge)
+	for chargeID, charge := range p.charges {
+		chargesCopy[chargeID] = charge
+	}
+	return chargesCopy
+}
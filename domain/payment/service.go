@@ -0,0 +1,93 @@
+package payment
+
+import (
+	"encoding/json"
+	"fmt"
+	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/domain/booking"
+	"ticket-booking-backend/domain/ticket"
+	"ticket-booking-backend/domain/user"
+	"ticket-booking-backend/dto"
+	"ticket-booking-backend/tool/rabbitmq"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bookingStore writes the bookings of paid holds, implemented by booking.BookingService
+type bookingStore interface {
+	GetEventSeats(eventID, rowID, startSeatNumber, length int) ([]booking.EventSeat, error)
+	CreateBookings(eventSeats []booking.EventSeat, userID int) error
+}
+
+// sessionUsers tells which user a session is logged in as, implemented by user.UserService
+type sessionUsers interface {
+	GetIDBySessionToken(sessionToken string) (int, error)
+}
+
+// sessionNotifier sends messages to the WebSocket connections of a session, implemented by websocket.ConnectionManager
+type sessionNotifier interface {
+	NotifySession(sessionID, msgType string, payload []byte) error
+}
+
+type PaymentService struct {
+	mq             *rabbitmq.RabbitMQ
+	provider       Provider
+	ticketService  *ticket.TicketService
+	bookingService bookingStore
+	userService    sessionUsers
+	notifier       sessionNotifier
+}
+
+func NewPaymentService(rmq *rabbitmq.RabbitMQ, provider Provider,
+	ticketService *ticket.TicketService,
+	bookingService *booking.BookingService,
+	userService *user.UserService,
+	connectionManager *websocket.ConnectionManager) *PaymentService {
+	return &PaymentService{
+		mq:             rmq,
+		provider:       provider,
+		ticketService:  ticketService,
+		bookingService: bookingService,
+		userService:    userService,
+		notifier:       connectionManager,
+	}
+}
+
+// RequestPayment checks the hold belongs to the caller and queues it for payment
+func (s *PaymentService) RequestPayment(ctx *gin.Context, eventID int, holdID string) error {
+	sessionID, exists := ctx.Get("session_id")
+	if !exists {
+		return fmt.Errorf("session ID not found in context")
+	}
+
+	userID, err := s.userService.GetIDBySessionToken(sessionID.(string))
+	if err != nil {
+		return err
+	}
+
+	hold, err := s.ticketService.GetHold(ctx.Request.Context(), sessionID.(string), holdID)
+	if err != nil {
+		return err
+	}
+	if hold.EventID != eventID {
+		return ticket.ErrHoldNotFound
+	}
+
+	msg := dto.PaymentMsg{
+		EventID:   eventID,
+		HoldID:    holdID,
+		UserID:    userID,
+		SessionID: sessionID.(string),
+	}
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	if err := s.mq.PublishMessage("pay", msgBytes); err != nil {
+		return fmt.Errorf("failed to push to message queue: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,33 @@
+-- Keys
+local reservationKey = KEYS[1]   -- session:{session_id}:reservations
+local holdDeadlinesKey = KEYS[2] -- hold_deadlines
+local claimKey = KEYS[3]         -- hold_claim:{session_id}|{hold_id}
+
+-- Get input arguments
+local holdID = ARGV[1]
+local deadlineMember = ARGV[2]   -- {session_id}|{hold_id}
+local token = ARGV[3]            -- identifies the claim to RestoreHold
+local leaseMs = ARGV[4]
+local leaseUntil = ARGV[5]       -- unix seconds
+local now = tonumber(ARGV[6])    -- unix seconds
+
+if redis.call("HEXISTS", reservationKey, holdID) == 0 then
+    return false
+end
+
+-- No deadline: released, or booked meanwhile.
+-- Past its deadline: expired, the reaper has not released it yet
+local deadline = redis.call("ZSCORE", holdDeadlinesKey, deadlineMember)
+if not deadline or tonumber(deadline) <= now then
+    return false
+end
+
+-- Someone else is booking it
+if not redis.call("SET", claimKey, token, "NX", "PX", leaseMs) then
+    return false
+end
+
+-- The lease replaces the deadline, the reaper releases the hold if the booking never finishes
+redis.call("ZADD", holdDeadlinesKey, "XX", leaseUntil, deadlineMember)
+
+return deadline
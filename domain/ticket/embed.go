@@ -1,16 +1,113 @@
 package ticket
 
 import (
+	"context"
 	_ "embed"
 	"fmt"
+	"ticket-booking-backend/dto"
+	"time"
+
+	redislib "github.com/redis/go-redis/v9"
 )
 
 //go:embed reserve_seats_return_broadcast_info.lua
 var luaBookingScript string
 
+// releaseHoldScript puts the seats of a hold back on sale, see releaseHold
+//
+//go:embed release_hold.lua
+var releaseHoldScript string
+
+// claimHoldScript and restoreHoldScript, see ClaimHold and RestoreHold
+var (
+	//go:embed claim_hold.lua
+	claimHoldScript string
+	//go:embed restore_hold.lua
+	restoreHoldScript string
+)
+
+// moveHoldsScript hands the holds of a session to another one, see MoveHolds
+//
+//go:embed move_holds.lua
+var moveHoldsScript string
+
 func readLuaBookingScript() (string, error) {
 	if luaBookingScript == "" {
 		return "", fmt.Errorf("lua booking script is empty")
 	}
 	return luaBookingScript, nil
 }
+
+// reserveSeatsLua runs the booking script with EVALSHA, so the whole reservation is one atomic server-side operation
+func (s *TicketService) reserveSeatsLua(ctx context.Context, msg dto.ReservationMsg) (reservationResult, error) {
+	keys := []string{
+		fmt.Sprintf("event:%d:section:%d:rows", msg.EventID, msg.SectionID),
+		fmt.Sprintf("event:%d:section:%d:price_blocks", msg.EventID, msg.SectionID),
+		getReservationKey(msg.SessionID),
+		holdDeadlinesKey,
+		getReservationAppliedKey(msg.RequestID),
+	}
+	now := time.Now()
+	args := []interface{}{
+		msg.RowID,
+		msg.Length,
+		fmt.Sprintf("%d:%d:%d", msg.EventID, msg.SectionID, msg.RowID),
+		int(holdTTL.Seconds()),
+		now.Add(holdTTL).Unix(),
+		msg.SessionID,
+		now.Unix(),
+		msg.Price,
+		msg.RequestID,
+		int(reservationRequestTTL.Seconds()),
+	}
+
+	reply, err := s.bookingScript.EvalSha(ctx, s.redisClient, keys, args...).Result()
+	if err != nil && redislib.HasErrorPrefix(err, "NOSCRIPT") {
+		// Script cache was flushed (restart, failover), load it again
+		if err := s.bookingScript.Load(ctx, s.redisClient).Err(); err != nil {
+			return reservationResult{}, fmt.Errorf("failed to reload booking script: %w", err)
+		}
+		reply, err = s.bookingScript.EvalSha(ctx, s.redisClient, keys, args...).Result()
+	}
+	if err != nil {
+		// Errors raised by the script with redis.error_reply
+		switch err.Error() {
+		case ErrRowNotFound.Error():
+			return reservationResult{}, ErrRowNotFound
+		case ErrNotEnoughSeats.Error():
+			return reservationResult{}, ErrNotEnoughSeats
+		case ErrSeatPriceMismatch.Error():
+			return reservationResult{}, ErrSeatPriceMismatch
+		case errReservationApplied.Error():
+			// A previous attempt that timed out applied it
+			applied, found, err := getAppliedReservation(ctx, s.redisClient, msg.RequestID)
+			if err == nil && !found {
+				err = fmt.Errorf("applied reservation request %s expired", msg.RequestID)
+			}
+			return applied, err
+		}
+		return reservationResult{}, err
+	}
+
+	// Reply: {start_seat_number, charged_price, price, max_length, price, max_length, ...}
+	values, ok := reply.([]interface{})
+	if !ok || len(values) < 2 || len(values)%2 != 0 {
+		return reservationResult{}, fmt.Errorf("unexpected booking script reply: %v", reply)
+	}
+
+	ints := make([]int, len(values))
+	for i, value := range values {
+		n, ok := value.(int64)
+		if !ok {
+			return reservationResult{}, fmt.Errorf("unexpected booking script reply value: %v", value)
+		}
+		ints[i] = int(n)
+	}
+
+	priceMaxConsecutive := map[int]int{}
+	for i := 2; i < len(ints); i += 2 {
+		priceMaxConsecutive[ints[i]] = ints[i+1]
+	}
+
+	return newRowReservationResult(msg.EventID, msg.SectionID, msg.RowID, ints[0], msg.Length, ints[1], priceMaxConsecutive), nil
+}
@@ -57,19 +57,132 @@ func getConsecutiveSeatBlocks(ctx context.Context, tx *redislib.Tx, eventID, sec
 	return consecutiveSeats, nil
 }
 
-func setReservation(ctx context.Context, tx *redislib.Tx, sessionID string, eventID, sectionID, rowID, startSeatNumber, length int) error {
-	reservationKey := fmt.Sprintf("session:%s:reservations", sessionID)
-	fieldKey := fmt.Sprintf("%d:%d:%d:%d:%d", eventID, sectionID, rowID, startSeatNumber, length)
+func getViewRanks(ctx context.Context, tx *redislib.Tx, eventID int, venueService *venue.VenueService) (venue.ViewRanks, error) {
+	viewRanks, err := getCachedViewRanks(ctx, tx, eventID)
+	if err != nil {
+		return venue.ViewRanks{}, err
+	}
 
-	// Set the reservation
-	if err := tx.HSet(ctx, reservationKey, fieldKey, "reserved").Err(); err != nil {
-		return fmt.Errorf("failed to set reservation: %w", err)
+	if len(viewRanks.Rows) == 0 {
+		viewRanks, err = cacheViewRanks(ctx, tx, eventID, venueService)
+		if err != nil {
+			return venue.ViewRanks{}, err
+		}
 	}
+	return viewRanks, nil
+}
+
+// holdTTL is how long seats stay held before the reaper releases them
+const holdTTL = 5 * time.Minute
+
+// holdMaxLifetime caps how long refreshing a hold can keep its seats off sale
+const holdMaxLifetime = 15 * time.Minute
+
+// holdDeadlinesKey is a sorted set of {session_id}|{hold_id} scored by the hold deadline (unix seconds)
+var holdDeadlinesKey = "hold_deadlines"
+
+func getHoldDeadlineMember(sessionID, holdID string) string {
+	return fmt.Sprintf("%s|%s", sessionID, holdID)
+}
+
+// HoldClaimTTL is how long a hold claimed for booking keeps its seats,
+// the reaper releases them once it runs out, e.g. when the booking instance crashed.
+// Booking a claimed hold must finish well within it.
+const HoldClaimTTL = 2 * time.Minute
 
-	// Set expiration
-	if err := tx.Expire(ctx, reservationKey, 5*time.Minute).Err(); err != nil {
-		return fmt.Errorf("failed to set expiration: %w", err)
+// getHoldClaimKey is set while the hold is being booked, its value is the token of the claim
+func getHoldClaimKey(sessionID, holdID string) string {
+	return fmt.Sprintf("hold_claim:%s", getHoldDeadlineMember(sessionID, holdID))
+}
+
+func getReservationKey(sessionID string) string {
+	return fmt.Sprintf("session:%s:reservations", sessionID)
+}
+
+func getHoldID(eventID, sectionID, rowID, startSeatNumber, length int) string {
+	return fmt.Sprintf("%d:%d:%d:%d:%d", eventID, sectionID, rowID, startSeatNumber, length)
+}
+
+// ParseHoldID decodes the field key written by setReservation
+func ParseHoldID(holdID string) (Hold, error) {
+	hold := Hold{ID: holdID}
+	_, err := fmt.Sscanf(holdID, "%d:%d:%d:%d:%d", &hold.EventID, &hold.SectionID, &hold.RowID, &hold.StartSeatNumber, &hold.Length)
+	if err != nil {
+		return Hold{}, fmt.Errorf("invalid hold id %q: %w", holdID, err)
+	}
+	// Sscanf ignores trailing characters and accepts signs, only the canonical form is a hold ID
+	if getHoldID(hold.EventID, hold.SectionID, hold.RowID, hold.StartSeatNumber, hold.Length) != holdID {
+		return Hold{}, fmt.Errorf("invalid hold id %q", holdID)
+	}
+	if hold.StartSeatNumber < 1 || hold.Length < 1 {
+		return Hold{}, fmt.Errorf("invalid hold id %q: seat range out of bounds", holdID)
+	}
+	return hold, nil
+}
+
+func setReservation(ctx context.Context, pipe redislib.Pipeliner, sessionID string, eventID, sectionID, rowID, startSeatNumber, length int) {
+	reservationKey := getReservationKey(sessionID)
+	fieldKey := getHoldID(eventID, sectionID, rowID, startSeatNumber, length)
+
+	// Set the reservation, the value is the creation time (unix seconds)
+	pipe.HSet(ctx, reservationKey, fieldKey, time.Now().Unix())
+
+	// Set expiration, the reaper releases the seats of each hold at its own deadline.
+	// Only ever extend it, a refreshed hold of the session may need longer than holdTTL:
+	// NX sets it on a new hash, GT extends it (GT counts a hash without expiration as never expiring)
+	pipe.ExpireNX(ctx, reservationKey, holdTTL)
+	pipe.ExpireGT(ctx, reservationKey, holdTTL)
+
+	pipe.ZAdd(ctx, holdDeadlinesKey, redislib.Z{
+		Score:  float64(time.Now().Add(holdTTL).Unix()),
+		Member: getHoldDeadlineMember(sessionID, fieldKey),
+	})
+}
+
+// getPriceMaxConsecutive calculates the max consecutive available seats per price in a row
+func getPriceMaxConsecutive(ctx context.Context, tx *redislib.Tx, priceBlocksKey string, rowID int, seats []rune) (map[int]int, error) {
+	priceBlocks, err := tx.ZRangeWithScores(ctx, priceBlocksKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get price blocks: %w", err)
+	}
+
+	priceMaxConsecutive := map[int]int{}
+	for _, block := range priceBlocks {
+		member := block.Member.(string)
+		price := int(block.Score)
+
+		// Extract start and end seat numbers from the block key
+		var blockRowID, startSeatID, startSeatNum, endSeatID, endSeatNum int
+		_, err := fmt.Sscanf(member, "%d:%d:%d:%d:%d", &blockRowID, &startSeatID, &startSeatNum, &endSeatID, &endSeatNum)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse price block: %w", err)
+		}
+
+		if blockRowID != rowID {
+			continue
+		}
+
+		// Calculate max consecutive available seats in this certain price block
+		maxLength, currentLength := 0, 0
+		for i := startSeatNum; i <= endSeatNum && i <= len(seats); i++ {
+			if seats[i-1] == '0' {
+				currentLength++
+			} else {
+				if currentLength > maxLength {
+					maxLength = currentLength
+				}
+				currentLength = 0
+			}
+		}
+		if currentLength > maxLength {
+			maxLength = currentLength
+		}
+
+		// A price can span several blocks in the same row
+		if length, exists := priceMaxConsecutive[price]; !exists || maxLength > length {
+			priceMaxConsecutive[price] = maxLength
+		}
 	}
 
-	return nil
+	return priceMaxConsecutive, nil
 }
@@ -0,0 +1,125 @@
+package ticket
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"ticket-booking-backend/domain/venue"
+	"time"
+
+	redislib "github.com/redis/go-redis/v9"
+)
+
+func TestParseHoldID(t *testing.T) {
+	tests := []struct {
+		name    string
+		holdID  string
+		want    Hold
+		wantErr bool
+	}{
+		{name: "valid", holdID: "1:2:3:4:5", want: Hold{ID: "1:2:3:4:5", EventID: 1, SectionID: 2, RowID: 3, StartSeatNumber: 4, Length: 5}},
+		{name: "round trip", holdID: getHoldID(10, 20, 30, 1, 6), want: Hold{ID: "10:20:30:1:6", EventID: 10, SectionID: 20, RowID: 30, StartSeatNumber: 1, Length: 6}},
+		{name: "empty", holdID: "", wantErr: true},
+		{name: "missing length", holdID: "1:2:3:4", wantErr: true},
+		{name: "not a number", holdID: "1:2:x:4:5", wantErr: true},
+		{name: "trailing characters", holdID: "1:2:3:4:5x", wantErr: true},
+		{name: "explicit sign", holdID: "+1:2:3:4:5", wantErr: true},
+		{name: "seat numbers start at 1", holdID: "1:2:3:0:5", wantErr: true},
+		{name: "empty seat range", holdID: "1:2:3:4:0", wantErr: true},
+		{name: "negative length", holdID: "1:2:3:4:-1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHoldID(tt.holdID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseHoldID(%q) error = %v, wantErr %v", tt.holdID, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseHoldID(%q) = %+v, want %+v", tt.holdID, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetReservationTTL(t *testing.T) {
+	tests := []struct {
+		name       string
+		engine     string
+		currentTTL time.Duration // 0 for a session without holds
+		wantTTL    time.Duration
+	}{
+		{name: "lua: first hold expires after holdTTL", engine: bookingEngineLua, wantTTL: holdTTL},
+		{name: "lua: shorter ttl is extended", engine: bookingEngineLua, currentTTL: time.Minute, wantTTL: holdTTL},
+		{name: "lua: refreshed ttl is kept", engine: bookingEngineLua, currentTTL: 10 * time.Minute, wantTTL: 10 * time.Minute},
+		{name: "go: first hold expires after holdTTL", engine: bookingEngineGo, wantTTL: holdTTL},
+		{name: "go: shorter ttl is extended", engine: bookingEngineGo, currentTTL: time.Minute, wantTTL: holdTTL},
+		{name: "go: refreshed ttl is kept", engine: bookingEngineGo, currentTTL: 10 * time.Minute, wantTTL: 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			s.bookingEngine = tt.engine
+			ctx := context.Background()
+			reservationKey := getReservationKey(testSessionID)
+
+			if tt.currentTTL > 0 {
+				redisClient.HSet(ctx, reservationKey, "1:2:3:4:1", time.Now().Unix())
+				redisClient.Expire(ctx, reservationKey, tt.currentTTL)
+			}
+
+			reserveTestHold(t, s, 2)
+
+			ttl, err := redisClient.TTL(ctx, reservationKey).Result()
+			if err != nil {
+				t.Fatalf("TTL() error = %v", err)
+			}
+			if ttl != tt.wantTTL {
+				t.Errorf("ttl = %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestGetCachedViewRanks(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  map[string]interface{}
+		want    venue.ViewRanks
+		wantErr bool
+	}{
+		{
+			name:   "sections and rows",
+			fields: map[string]interface{}{"section:2": 1, "section:5": 2, "row:3": 1, "row:4": 2},
+			want:   venue.ViewRanks{Sections: map[int]int{2: 1, 5: 2}, Rows: map[int]int{3: 1, 4: 2}},
+		},
+		{name: "not cached", want: venue.ViewRanks{Sections: map[int]int{}, Rows: map[int]int{}}},
+		{name: "unknown kind", fields: map[string]interface{}{"seat:3": 1}, wantErr: true},
+		{name: "invalid ID", fields: map[string]interface{}{"row:x": 1}, wantErr: true},
+		{name: "invalid rank", fields: map[string]interface{}{"row:3": "front"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			_, redisClient := newTestTicketService(t)
+			if tt.fields != nil {
+				redisClient.HSet(ctx, getViewRanksKey(1), tt.fields)
+			}
+
+			var got venue.ViewRanks
+			err := redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+				var err error
+				got, err = getCachedViewRanks(ctx, tx, 1)
+				return err
+			})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("getCachedViewRanks() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("getCachedViewRanks() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
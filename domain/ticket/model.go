@@ -1,5 +1,7 @@
 package ticket
 
+import "time"
+
 type Ticket struct {
 	EventID     int    `json:"event_id"`
 	SectionID   int    `json:"section_id"`
@@ -10,8 +12,144 @@ type Ticket struct {
 	Length      int    `json:"length"`
 }
 
-type priceInfo struct {
-	SectionID int
-	RowID     int
-	Length    int
+// TicketSearch is a ticket query, see GetTickets and GetTicketRuns.
+// Page is ignored when Cursor, the NextCursor of the previous page, is set.
+type TicketSearch struct {
+	EventID   int
+	Number    int
+	LowPrice  int
+	HighPrice int
+	Sort      string // one of the Sort* constants, price_asc by default
+	Page      int
+	PageSize  int
+	Cursor    string
+}
+
+type TicketPage struct {
+	Tickets    []Ticket `json:"tickets"`
+	NextCursor string   `json:"next_cursor,omitempty"` // empty on the last page
+	Total      int      `json:"total"`
+}
+
+type TicketRunPage struct {
+	Tickets    []TicketRun `json:"tickets"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	Total      int         `json:"total"`
+}
+
+// TicketRun is a run of consecutive available seats sharing a price.
+// StartSeatNumbers are the seat numbers the requested number of seats can start at.
+type TicketRun struct {
+	EventID          int    `json:"event_id"`
+	SectionID        int    `json:"section_id"`
+	SectionName      string `json:"section_name"`
+	RowID            int    `json:"row_id"`
+	RowName          string `json:"row_name"`
+	Price            int    `json:"price"`
+	Length           int    `json:"length"`
+	StartSeatNumbers []int  `json:"start_seat_numbers"`
+}
+
+// rowData is the value cached per row in event:<e>:section:<s>:rows
+type rowData struct {
+	RowName string `json:"row_name"`
+	Seats   string `json:"seats"` // "0":available, "1":non-available
+}
+
+type reservationResult struct {
+	Holds []Hold            // one per run of consecutive seats, a single one unless specific seats are reserved
+	Rows  []rowAvailability // rows whose availability changed, to broadcast
+	Price int               // charged per seat, from the price blocks of the held seats
+}
+
+type rowAvailability struct {
+	SectionID           int
+	RowID               int
+	PriceMaxConsecutive map[int]int
+}
+
+// newRowReservationResult is the result of seats reserved in a single run of a row
+func newRowReservationResult(eventID, sectionID, rowID, startSeatNumber, length, price int, priceMaxConsecutive map[int]int) reservationResult {
+	return reservationResult{
+		Price: price,
+		Holds: []Hold{{
+			ID:              getHoldID(eventID, sectionID, rowID, startSeatNumber, length),
+			EventID:         eventID,
+			SectionID:       sectionID,
+			RowID:           rowID,
+			StartSeatNumber: startSeatNumber,
+			Length:          length,
+		}},
+		Rows: []rowAvailability{{
+			SectionID:           sectionID,
+			RowID:               rowID,
+			PriceMaxConsecutive: priceMaxConsecutive,
+		}},
+	}
+}
+
+type seatRun struct {
+	SectionID       int
+	RowID           int
+	SectionRank     int // view ranks, see venue.Section
+	RowRank         int
+	Price           int
+	StartSeatNumber int
+	Length          int
+	Seats           string // availability of the whole row, "0":available
+}
+
+// Hold is a temporary seat reservation stored in session:<id>:reservations.
+// ID is the hash field key: {event_id}:{section_id}:{row_id}:{start_seat_number}:{length}
+type Hold struct {
+	ID              string `json:"hold_id"`
+	EventID         int    `json:"event_id"`
+	SectionID       int    `json:"section_id"`
+	RowID           int    `json:"row_id"`
+	StartSeatNumber int    `json:"start_seat_number"`
+	Length          int    `json:"length"`
+}
+
+// HoldClaim is a hold leased for booking by ClaimHold
+type HoldClaim struct {
+	SessionID string
+	HoldID    string
+	Deadline  time.Time // of the hold before the claim, given back by RestoreHold
+	token     string
+}
+
+type BookedHold struct {
+	HoldID      string `json:"hold_id"`
+	SectionID   int    `json:"section_id"`
+	RowID       int    `json:"row_id"`
+	SeatNumbers []int  `json:"seat_numbers"`
+}
+
+type HoldInfo struct {
+	Hold
+	EventName   string    `json:"event_name"`
+	SectionName string    `json:"section_name"`
+	RowName     string    `json:"row_name"`
+	SeatNumbers []int     `json:"seat_numbers"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	ExpiresIn   int       `json:"expires_in"` // seconds
+}
+
+// Status of a ReservationRequest
+const (
+	RequestStatusPending   = "pending"
+	RequestStatusSucceeded = "succeeded"
+	RequestStatusFailed    = "failed"
+)
+
+// ReservationRequest tracks an asynchronous reservation for clients polling without a WebSocket
+type ReservationRequest struct {
+	RequestID string   `json:"request_id"`
+	SessionID string   `json:"session_id"`
+	EventID   int      `json:"event_id"`
+	Status    string   `json:"status"`
+	Reason    string   `json:"reason,omitempty"`
+	Message   string   `json:"message,omitempty"`
+	HoldID    string   `json:"hold_id,omitempty"`  // the first hold
+	HoldIDs   []string `json:"hold_ids,omitempty"` // every hold, specific seats are held per run of consecutive seats
 }
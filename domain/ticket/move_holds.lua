@@ -0,0 +1,40 @@
+-- Keys
+local fromReservationKey = KEYS[1] -- session:{from_session_id}:reservations
+local toReservationKey = KEYS[2]   -- session:{to_session_id}:reservations
+local holdDeadlinesKey = KEYS[3]   -- hold_deadlines
+-- KEYS[4...]: hold_claim:{from_session_id}|{hold_id} of each hold, in the order of the hold IDs
+
+-- Get input arguments
+local fromSessionID = ARGV[1]
+local toSessionID = ARGV[2]
+-- ARGV[3...]: hold IDs
+
+local ttl = redis.call("PTTL", fromReservationKey)
+
+local moved = {}
+for i = 3, #ARGV do
+    local holdID = ARGV[i]
+    local createdAt = redis.call("HGET", fromReservationKey, holdID)
+
+    -- A claimed hold is being paid for the old session, it stays there
+    if createdAt and redis.call("EXISTS", KEYS[i + 1]) == 0 then
+        local fromMember = fromSessionID .. "|" .. holdID
+        local deadline = redis.call("ZSCORE", holdDeadlinesKey, fromMember)
+        if deadline then
+            redis.call("ZREM", holdDeadlinesKey, fromMember)
+            redis.call("ZADD", holdDeadlinesKey, deadline, toSessionID .. "|" .. holdID)
+        end
+
+        redis.call("HDEL", fromReservationKey, holdID)
+        redis.call("HSET", toReservationKey, holdID, createdAt)
+        table.insert(moved, holdID)
+    end
+end
+
+-- Only ever extend the expiration, same as the booking script
+if #moved > 0 and ttl > 0 then
+    redis.call("PEXPIRE", toReservationKey, ttl, "NX")
+    redis.call("PEXPIRE", toReservationKey, ttl, "GT")
+end
+
+return moved
@@ -0,0 +1,120 @@
+package ticket
+
+import (
+	"log"
+	"ticket-booking-backend/tool/util"
+)
+
+// SeatPicker chooses where to seat length people among runs of available seats at the requested price.
+// It returns the run and the seat number to start at, false when no run is long enough.
+type SeatPicker interface {
+	Pick(runs []seatRun, length int) (seatRun, int, bool)
+}
+
+// Seat pickers, selected with SEAT_PICKER
+const (
+	seatPickerBest     = "best"      // the default
+	seatPickerFirstFit = "first_fit" // first seats from the left of the first row, as a row reservation does
+)
+
+func newSeatPicker() SeatPicker {
+	switch name := util.GetEnvOrDefault("SEAT_PICKER", seatPickerBest); name {
+	case seatPickerFirstFit:
+		return firstFitPicker{}
+	case seatPickerBest:
+		return bestAvailablePicker{}
+	default:
+		log.Fatalf(`Unknown seat picker : %s, should be either "best" or "first_fit"`, name)
+		return nil
+	}
+}
+
+// bestAvailablePicker ranks the seats by, in order:
+//   - section, then row, by view rank, like SortBestView
+//   - single seat gaps left next to the seats, nobody buys them
+//   - distance between the middle of the seats and the middle of the row
+type bestAvailablePicker struct{}
+
+func (bestAvailablePicker) Pick(runs []seatRun, length int) (seatRun, int, bool) {
+	var best seatRun
+	var bestStart int
+	var bestScore []int
+
+	for _, run := range runs {
+		for start := run.StartSeatNumber; start+length <= run.StartSeatNumber+run.Length; start++ {
+			score := []int{
+				run.SectionRank,
+				run.RowRank,
+				countSingleSeatGaps(run.Seats, start, length),
+				distanceToRowCenter(len(run.Seats), start, length),
+				run.SectionID,
+				run.RowID,
+				start,
+			}
+			if bestScore == nil || lessScore(score, bestScore) {
+				best, bestStart, bestScore = run, start, score
+			}
+		}
+	}
+
+	return best, bestStart, bestScore != nil
+}
+
+// firstFitPicker takes the first run long enough in the SortBestView order
+type firstFitPicker struct{}
+
+func (firstFitPicker) Pick(runs []seatRun, length int) (seatRun, int, bool) {
+	var best seatRun
+	found := false
+	for _, run := range runs {
+		if run.Length < length {
+			continue
+		}
+		if !found || compareRuns(run, best, SortBestView) < 0 {
+			best, found = run, true
+		}
+	}
+	return best, best.StartSeatNumber, found
+}
+
+// countSingleSeatGaps counts the sides of the seats left with exactly one available seat.
+// The whole row is checked, available seats of another price count too.
+func countSingleSeatGaps(seats string, start, length int) int {
+	gaps := 0
+
+	left := 0
+	for i := start - 2; i >= 0 && seats[i] == '0'; i-- {
+		left++
+	}
+	if left == 1 {
+		gaps++
+	}
+
+	right := 0
+	for i := start + length - 1; i < len(seats) && seats[i] == '0'; i++ {
+		right++
+	}
+	if right == 1 {
+		gaps++
+	}
+
+	return gaps
+}
+
+// distanceToRowCenter is doubled to stay an integer
+func distanceToRowCenter(rowLength, start, length int) int {
+	distance := (2*start + length - 1) - (rowLength + 1)
+	if distance < 0 {
+		return -distance
+	}
+	return distance
+}
+
+func lessScore(left, right []int) bool {
+	for i := range left {
+		if left[i] != right[i] {
+			return left[i] < right[i]
+		}
+	}
+	return false
+}
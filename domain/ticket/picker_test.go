@@ -0,0 +1,203 @@
+package ticket
+
+import "testing"
+
+func TestCountSingleSeatGaps(t *testing.T) {
+	tests := []struct {
+		name   string
+		seats  string
+		start  int
+		length int
+		want   int
+	}{
+		{name: "whole row", seats: "00", start: 1, length: 2, want: 0},
+		{name: "against the left end", seats: "0000", start: 1, length: 2, want: 0},
+		{name: "one seat left on the right", seats: "000", start: 1, length: 2, want: 1},
+		{name: "one seat left on each side", seats: "0000", start: 2, length: 2, want: 2},
+		{name: "against taken seats", seats: "1001", start: 2, length: 2, want: 0},
+		{name: "two seats left on the left", seats: "0000", start: 3, length: 2, want: 0},
+		{name: "single seat next to a taken seat", seats: "1000", start: 3, length: 2, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := countSingleSeatGaps(tt.seats, tt.start, tt.length); got != tt.want {
+				t.Errorf("countSingleSeatGaps(%q, %d, %d) = %d, want %d", tt.seats, tt.start, tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistanceToRowCenter(t *testing.T) {
+	tests := []struct {
+		name      string
+		rowLength int
+		start     int
+		length    int
+		want      int
+	}{
+		{name: "centered in an even row", rowLength: 10, start: 5, length: 2, want: 0},
+		{name: "centered in an odd row", rowLength: 5, start: 3, length: 1, want: 0},
+		{name: "half a seat off", rowLength: 5, start: 2, length: 2, want: 1},
+		{name: "left end", rowLength: 10, start: 1, length: 2, want: 8},
+		{name: "right end is as far", rowLength: 10, start: 9, length: 2, want: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := distanceToRowCenter(tt.rowLength, tt.start, tt.length); got != tt.want {
+				t.Errorf("distanceToRowCenter(%d, %d, %d) = %d, want %d", tt.rowLength, tt.start, tt.length, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLessScore(t *testing.T) {
+	tests := []struct {
+		name        string
+		left, right []int
+		want        bool
+	}{
+		{name: "first value decides", left: []int{1, 9}, right: []int{2, 0}, want: true},
+		{name: "next value breaks ties", left: []int{1, 2}, right: []int{1, 1}, want: false},
+		{name: "equal", left: []int{1, 1}, right: []int{1, 1}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lessScore(tt.left, tt.right); got != tt.want {
+				t.Errorf("lessScore(%v, %v) = %v, want %v", tt.left, tt.right, got, tt.want)
+			}
+		})
+	}
+}
+
+// seatsRun ranks the sections and rows by ID, like venues listed from the stage backwards
+func seatsRun(sectionID, rowID, startSeatNumber, length int, seats string) seatRun {
+	return seatRun{
+		SectionID: sectionID, RowID: rowID, SectionRank: sectionID, RowRank: rowID,
+		Price: 100, StartSeatNumber: startSeatNumber, Length: length, Seats: seats,
+	}
+}
+
+func TestSeatPickers(t *testing.T) {
+	tests := []struct {
+		name      string
+		picker    SeatPicker
+		runs      []seatRun
+		length    int
+		wantRun   seatRun
+		wantStart int
+		wantFound bool
+	}{
+		{
+			name:      "best: centre of the row",
+			picker:    bestAvailablePicker{},
+			runs:      []seatRun{seatsRun(1, 10, 1, 10, "0000000000")},
+			length:    2,
+			wantRun:   seatsRun(1, 10, 1, 10, "0000000000"),
+			wantStart: 5,
+			wantFound: true,
+		},
+		{
+			name:   "best: front section before a better seat further back",
+			picker: bestAvailablePicker{},
+			runs: []seatRun{
+				seatsRun(2, 20, 1, 10, "0000000000"),
+				seatsRun(1, 19, 1, 2, "0011111111"),
+			},
+			length:    2,
+			wantRun:   seatsRun(1, 19, 1, 2, "0011111111"),
+			wantStart: 1,
+			wantFound: true,
+		},
+		{
+			name:   "best: front row of the section",
+			picker: bestAvailablePicker{},
+			runs: []seatRun{
+				seatsRun(1, 11, 1, 4, "0000"),
+				seatsRun(1, 10, 3, 2, "1100"),
+			},
+			length:    2,
+			wantRun:   seatsRun(1, 10, 3, 2, "1100"),
+			wantStart: 3,
+			wantFound: true,
+		},
+		{
+			name:   "best: front section by rank, not by ID",
+			picker: bestAvailablePicker{},
+			runs: []seatRun{
+				ranked(seatsRun(1, 10, 1, 10, "0000000000"), 2, 1),
+				ranked(seatsRun(2, 20, 1, 2, "0011111111"), 1, 1),
+			},
+			length:    2,
+			wantRun:   ranked(seatsRun(2, 20, 1, 2, "0011111111"), 1, 1),
+			wantStart: 1,
+			wantFound: true,
+		},
+		{
+			name:   "best: no single seat left over, even off centre",
+			picker: bestAvailablePicker{},
+			runs: []seatRun{
+				seatsRun(1, 10, 1, 3, "000100"),
+				seatsRun(1, 10, 5, 2, "000100"),
+			},
+			length:    2,
+			wantRun:   seatsRun(1, 10, 5, 2, "000100"),
+			wantStart: 5,
+			wantFound: true,
+		},
+		{
+			name:   "best: no run long enough",
+			picker: bestAvailablePicker{},
+			runs:   []seatRun{seatsRun(1, 10, 1, 2, "0011")},
+			length: 3,
+		},
+		{
+			name:   "first fit: left of the front row",
+			picker: firstFitPicker{},
+			runs: []seatRun{
+				seatsRun(1, 11, 1, 10, "0000000000"),
+				seatsRun(1, 10, 1, 2, "0010000000"),
+				seatsRun(1, 10, 4, 7, "0010000000"),
+			},
+			length:    3,
+			wantRun:   seatsRun(1, 10, 4, 7, "0010000000"),
+			wantStart: 4,
+			wantFound: true,
+		},
+		{
+			name:   "first fit: front row by rank, not by ID",
+			picker: firstFitPicker{},
+			runs: []seatRun{
+				ranked(seatsRun(1, 10, 1, 4, "0000"), 1, 2),
+				ranked(seatsRun(1, 11, 1, 4, "0000"), 1, 1),
+			},
+			length:    2,
+			wantRun:   ranked(seatsRun(1, 11, 1, 4, "0000"), 1, 1),
+			wantStart: 1,
+			wantFound: true,
+		},
+		{
+			name:   "first fit: no run long enough",
+			picker: firstFitPicker{},
+			runs:   []seatRun{seatsRun(1, 10, 1, 2, "0011")},
+			length: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotRun, gotStart, gotFound := tt.picker.Pick(tt.runs, tt.length)
+			if gotFound != tt.wantFound {
+				t.Fatalf("Pick() found = %v, want %v", gotFound, tt.wantFound)
+			}
+			if !gotFound {
+				return
+			}
+			if gotRun != tt.wantRun || gotStart != tt.wantStart {
+				t.Errorf("Pick() = %+v from seat %d, want %+v from seat %d", gotRun, gotStart, tt.wantRun, tt.wantStart)
+			}
+		})
+	}
+}
@@ -0,0 +1,324 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+var reaperBatchSize int64 = 100
+
+// StartHoldReaper releases expired holds every interval until ctx is cancelled
+func (s *TicketService) StartHoldReaper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.releaseExpiredHolds(ctx); err != nil {
+				log.Printf("failed to release expired holds: %v", err)
+			}
+		}
+	}
+}
+
+// releaseExpiredHolds releases batches of expired holds until none is left.
+// Instances reaping at the same time only race on the script, a hold released twice is a no-op.
+func (s *TicketService) releaseExpiredHolds(ctx context.Context) error {
+	now := time.Now().Unix()
+
+	// Holds left in place are skipped with the offset
+	var skipped int64
+	for {
+		members, err := s.redisClient.ZRangeByScore(ctx, holdDeadlinesKey, &redislib.ZRangeBy{
+			Min:    "-inf",
+			Max:    strconv.FormatInt(now, 10),
+			Offset: skipped,
+			Count:  reaperBatchSize,
+		}).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get expired holds: %w", err)
+		}
+
+		for _, member := range members {
+			sessionID, holdID, found := strings.Cut(member, "|")
+			hold, err := ParseHoldID(holdID)
+			if !found || err != nil {
+				log.Printf("dropping malformed hold deadline: %s", member)
+				if err := s.redisClient.ZRem(ctx, holdDeadlinesKey, member).Err(); err != nil {
+					return fmt.Errorf("failed to drop malformed hold deadline: %w", err)
+				}
+				continue
+			}
+
+			// The deadline is only dropped along with the seats, a failed release is retried on the next tick.
+			// A hold kept in place, claimed or refreshed meanwhile, is skipped too.
+			if err := s.releaseHold(ctx, sessionID, hold, now); err != nil {
+				if !errors.Is(err, ErrHoldNotFound) {
+					log.Printf("failed to release hold %s: %v", holdID, err)
+				}
+				skipped++
+			}
+		}
+
+		if int64(len(members)) < reaperBatchSize {
+			return nil
+		}
+	}
+}
+
+// releaseHold puts the seats of a hold back on sale, drops the hold from the session
+// and broadcasts the new max consecutive lengths of the row.
+// It runs release_hold.lua: only the caller that removes the hold deadline releases the seats,
+// a hold claimed for booking, booked or released by another instance returns ErrHoldNotFound.
+// With expiredAt set, a hold whose deadline was pushed past it meanwhile is kept.
+func (s *TicketService) releaseHold(ctx context.Context, sessionID string, hold Hold, expiredAt int64) error {
+	keys := []string{
+		fmt.Sprintf("event:%d:section:%d:rows", hold.EventID, hold.SectionID),
+		fmt.Sprintf("event:%d:section:%d:price_blocks", hold.EventID, hold.SectionID),
+		getReservationKey(sessionID),
+		holdDeadlinesKey,
+		getHoldClaimKey(sessionID, hold.ID),
+	}
+	onlyExpired := 0
+	if expiredAt > 0 {
+		onlyExpired = 1
+	}
+	args := []interface{}{
+		hold.RowID,
+		hold.StartSeatNumber,
+		hold.Length,
+		hold.ID,
+		getHoldDeadlineMember(sessionID, hold.ID),
+		expiredAt,
+		onlyExpired,
+	}
+
+	reply, err := s.releaseScript.Run(ctx, s.redisClient, keys, args...).Int64Slice()
+	if err == redislib.Nil {
+		return ErrHoldNotFound
+	} else if err != nil {
+		return fmt.Errorf("failed to release hold: %w", err)
+	}
+
+	// Reply: {price, max_length, price, max_length, ...}, empty when the row is not cached anymore
+	priceMaxConsecutive := map[int]int{}
+	for i := 0; i+1 < len(reply); i += 2 {
+		priceMaxConsecutive[int(reply[i])] = int(reply[i+1])
+	}
+
+	if len(priceMaxConsecutive) > 0 {
+		if err := s.broadcastReservation(hold.EventID, hold.SectionID, hold.RowID, priceMaxConsecutive); err != nil {
+			log.Printf("failed to broadcast released hold: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// CancelHold releases a hold of the session before it expires
+func (s *TicketService) CancelHold(ctx context.Context, sessionID string, eventID int, holdID string) error {
+	hold, err := s.GetHold(ctx, sessionID, holdID)
+	if err != nil {
+		return err
+	}
+	if hold.EventID != eventID {
+		return ErrHoldNotFound
+	}
+
+	// ErrHoldNotFound when the reaper released it or it is being booked
+	return s.releaseHold(ctx, sessionID, hold, 0)
+}
+
+// CancelHolds releases every hold of the session for the event and returns the cancelled hold IDs
+func (s *TicketService) CancelHolds(ctx context.Context, sessionID string, eventID int) ([]string, error) {
+	holds, err := s.GetHolds(ctx, sessionID, eventID)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelled := []string{}
+	for _, hold := range holds {
+		if err := s.CancelHold(ctx, sessionID, eventID, hold.ID); err != nil {
+			if err == ErrHoldNotFound { // expired meanwhile
+				continue
+			}
+			return cancelled, err
+		}
+		cancelled = append(cancelled, hold.ID)
+	}
+
+	return cancelled, nil
+}
+
+// ClaimHold leases the hold before it is booked, so neither the reaper nor a cancellation releases its seats
+// for HoldClaimTTL. The lease replaces the hold deadline: if the booking never finishes, the reaper releases
+// the hold once it runs out. A failed booking gives the deadline back with RestoreHold.
+// A hold past its deadline is expired and returns ErrHoldNotFound, even before the reaper released it.
+func (s *TicketService) ClaimHold(ctx context.Context, sessionID, holdID string) (HoldClaim, error) {
+	claim := HoldClaim{SessionID: sessionID, HoldID: holdID, token: uuid.NewString()}
+
+	keys := []string{getReservationKey(sessionID), holdDeadlinesKey, getHoldClaimKey(sessionID, holdID)}
+	now := time.Now()
+	args := []interface{}{
+		holdID,
+		getHoldDeadlineMember(sessionID, holdID),
+		claim.token,
+		HoldClaimTTL.Milliseconds(),
+		now.Add(HoldClaimTTL).Unix(),
+		now.Unix(),
+	}
+
+	deadline, err := s.claimScript.Run(ctx, s.redisClient, keys, args...).Int64()
+	if err == redislib.Nil {
+		return HoldClaim{}, ErrHoldNotFound
+	} else if err != nil {
+		return HoldClaim{}, fmt.Errorf("failed to claim hold: %w", err)
+	}
+
+	claim.Deadline = time.Unix(deadline, 0)
+	return claim, nil
+}
+
+// RestoreHold ends the claim and gives back the hold deadline, the reaper releases the hold once it passes.
+// A claim whose lease ran out is left alone, the hold may have been released or claimed again.
+func (s *TicketService) RestoreHold(ctx context.Context, claim HoldClaim) error {
+	keys := []string{holdDeadlinesKey, getHoldClaimKey(claim.SessionID, claim.HoldID)}
+	args := []interface{}{
+		claim.token,
+		getHoldDeadlineMember(claim.SessionID, claim.HoldID),
+		claim.Deadline.Unix(),
+	}
+
+	if err := s.restoreScript.Run(ctx, s.redisClient, keys, args...).Err(); err != nil {
+		return fmt.Errorf("failed to restore hold deadline: %w", err)
+	}
+	return nil
+}
+
+// MoveHolds hands the holds of a session over to another one, with their deadlines, when the session ID changes.
+// Holds being paid stay with the old session, as do reservations still queued for it.
+// It returns the IDs of the moved holds.
+func (s *TicketService) MoveHolds(ctx context.Context, fromSessionID, toSessionID string) ([]string, error) {
+	holdIDs, err := s.redisClient.HKeys(ctx, getReservationKey(fromSessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get holds: %w", err)
+	}
+	if len(holdIDs) == 0 {
+		return []string{}, nil
+	}
+
+	keys := []string{getReservationKey(fromSessionID), getReservationKey(toSessionID), holdDeadlinesKey}
+	args := []interface{}{fromSessionID, toSessionID}
+	for _, holdID := range holdIDs {
+		keys = append(keys, getHoldClaimKey(fromSessionID, holdID))
+		args = append(args, holdID)
+	}
+
+	moved, err := s.moveScript.Run(ctx, s.redisClient, keys, args...).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("failed to move holds: %w", err)
+	}
+	return moved, nil
+}
+
+// RefreshHold pushes the deadline of a hold back to holdTTL from now,
+// but never past holdMaxLifetime after the hold was created.
+func (s *TicketService) RefreshHold(ctx context.Context, sessionID string, eventID int, holdID string) (time.Time, error) {
+	hold, err := ParseHoldID(holdID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if hold.EventID != eventID {
+		return time.Time{}, ErrHoldNotFound
+	}
+
+	reservationKey := getReservationKey(sessionID)
+	deadlineMember := getHoldDeadlineMember(sessionID, holdID)
+	claimKey := getHoldClaimKey(sessionID, holdID)
+
+	var deadline time.Time
+
+	// The session hash is watched so a hold released meanwhile is not written back,
+	// the claim so a lease taken meanwhile is not overwritten
+	err = s.redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+		createdAtStr, err := tx.HGet(ctx, reservationKey, holdID).Result()
+		if err == redislib.Nil {
+			return ErrHoldNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get hold: %w", err)
+		}
+
+		// A hold claimed for booking cannot be refreshed
+		claimed, err := tx.Exists(ctx, claimKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get hold claim: %w", err)
+		}
+		if claimed > 0 {
+			return ErrHoldNotFound
+		}
+
+		if err := tx.ZScore(ctx, holdDeadlinesKey, deadlineMember).Err(); err == redislib.Nil {
+			return ErrHoldNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get hold deadline: %w", err)
+		}
+
+		// A creation time that is not a unix timestamp starts the lifetime now, it is written back so the cap holds
+		now := time.Now()
+		createdAt, err := strconv.ParseInt(createdAtStr, 10, 64)
+		resetCreatedAt := err != nil
+		if resetCreatedAt {
+			createdAt = now.Unix()
+		}
+		deadline = getRefreshedDeadline(now, createdAt)
+
+		ttl, err := tx.TTL(ctx, reservationKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get hold ttl: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redislib.Pipeliner) error {
+			if resetCreatedAt {
+				pipe.HSet(ctx, reservationKey, holdID, createdAt)
+			}
+			pipe.ZAddXX(ctx, holdDeadlinesKey, redislib.Z{
+				Score:  float64(deadline.Unix()),
+				Member: deadlineMember,
+			})
+			// The hash holds every hold of the session, only ever extend it
+			if until := time.Until(deadline); until > ttl {
+				pipe.Expire(ctx, reservationKey, until)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to refresh hold: %w", err)
+		}
+		return nil
+	}, reservationKey, claimKey)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return deadline, nil
+}
+
+// getRefreshedDeadline is holdTTL from now, but never past holdMaxLifetime after the hold was created
+func getRefreshedDeadline(now time.Time, createdAt int64) time.Time {
+	deadline := now.Add(holdTTL)
+	if maxDeadline := time.Unix(createdAt, 0).Add(holdMaxLifetime); deadline.After(maxDeadline) {
+		return maxDeadline
+	}
+	return deadline
+}
@@ -0,0 +1,477 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"ticket-booking-backend/cmd/api/websocket"
+	"ticket-booking-backend/dto"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+const (
+	testSessionID = "session-1"
+	testSeatsKey  = "event:1:section:2:rows"
+)
+
+// newTestTicketService returns a ticket service on an in-memory redis holding row 3 of section 2 of event 1,
+// four seats sold at 100
+func newTestTicketService(t *testing.T) (*TicketService, *redislib.Client) {
+	t.Helper()
+	t.Setenv("BROADCAST_WINDOW_MS", "0")
+
+	mr := miniredis.RunT(t)
+	redisClient := redislib.NewClient(&redislib.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	ctx := context.Background()
+	redisClient.HSet(ctx, testSeatsKey, "3", `{"row_name":"A","seats":"0000"}`)
+	redisClient.ZAdd(ctx, "event:1:section:2:price_blocks", redislib.Z{Score: 100, Member: "3:301:1:304:4"})
+
+	cm := websocket.NewConnectionManager(redisClient, nil)
+	return NewTicketService(redisClient, nil, nil, nil, cm), redisClient
+}
+
+func reserveTestHold(t *testing.T, s *TicketService, length int) Hold {
+	t.Helper()
+
+	result, err := s.reserveSeatsWithRetry(dto.ReservationMsg{
+		EventID:   1,
+		SectionID: 2,
+		RowID:     3,
+		Price:     100,
+		Length:    length,
+		SessionID: testSessionID,
+	})
+	if err != nil || len(result.Holds) != 1 {
+		t.Fatalf("failed to reserve: %v", err)
+	}
+	return result.Holds[0]
+}
+
+func getTestSeats(t *testing.T, redisClient *redislib.Client) string {
+	t.Helper()
+
+	rowData, err := redisClient.HGet(context.Background(), testSeatsKey, "3").Result()
+	if err != nil {
+		t.Fatalf("failed to get row: %v", err)
+	}
+	return rowData
+}
+
+func TestReleaseExpiredHolds(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name      string
+		expired   bool
+		claim     bool // claimed for booking before the reaper runs
+		restore   bool // booking failed and gave the deadline back
+		crash     bool // the booking instance died, the lease ran out
+		wantSeats string
+		wantHold  bool
+	}{
+		{name: "expired hold is released", expired: true, wantSeats: "0000"},
+		{name: "hold before its deadline is kept", wantSeats: "1100", wantHold: true},
+		{name: "hold claimed for booking is kept", expired: true, claim: true, wantSeats: "1100", wantHold: true},
+		{name: "restored hold is released", expired: true, claim: true, restore: true, wantSeats: "0000"},
+		{name: "hold of a lost claim is released", claim: true, crash: true, wantSeats: "0000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			hold := reserveTestHold(t, s, 2)
+			deadlineMember := getHoldDeadlineMember(testSessionID, hold.ID)
+
+			if tt.claim {
+				claim, err := s.ClaimHold(ctx, testSessionID, hold.ID)
+				if err != nil {
+					t.Fatalf("ClaimHold() error = %v", err)
+				}
+				if tt.restore {
+					if err := s.RestoreHold(ctx, claim); err != nil {
+						t.Fatalf("RestoreHold() error = %v", err)
+					}
+				}
+				if tt.crash {
+					redisClient.Del(ctx, getHoldClaimKey(testSessionID, hold.ID))
+					redisClient.ZAddXX(ctx, holdDeadlinesKey, redislib.Z{Score: float64(past.Unix()), Member: deadlineMember})
+				}
+			}
+			if tt.expired {
+				redisClient.ZAddXX(ctx, holdDeadlinesKey, redislib.Z{Score: float64(past.Unix()), Member: deadlineMember})
+			}
+
+			if err := s.releaseExpiredHolds(ctx); err != nil {
+				t.Fatalf("releaseExpiredHolds() error = %v", err)
+			}
+
+			if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"`+tt.wantSeats+`"}`; got != want {
+				t.Errorf("row = %s, want %s", got, want)
+			}
+			_, err := s.GetHold(ctx, testSessionID, hold.ID)
+			if gotHold := err == nil; gotHold != tt.wantHold {
+				t.Errorf("hold still there = %v, want %v (err: %v)", gotHold, tt.wantHold, err)
+			}
+		})
+	}
+}
+
+func TestReleaseExpiredHoldsBatches(t *testing.T) {
+	defer func(batchSize int64) { reaperBatchSize = batchSize }(reaperBatchSize)
+	reaperBatchSize = 1
+
+	s, redisClient := newTestTicketService(t)
+	ctx := context.Background()
+	past := time.Now().Add(-time.Minute)
+
+	// Two expired holds and a malformed deadline, more than a batch
+	for i := 0; i < 2; i++ {
+		hold := reserveTestHold(t, s, 2)
+		redisClient.ZAddXX(ctx, holdDeadlinesKey, redislib.Z{Score: float64(past.Unix()), Member: getHoldDeadlineMember(testSessionID, hold.ID)})
+	}
+	redisClient.ZAdd(ctx, holdDeadlinesKey, redislib.Z{Score: float64(past.Unix()), Member: "malformed"})
+
+	if err := s.releaseExpiredHolds(ctx); err != nil {
+		t.Fatalf("releaseExpiredHolds() error = %v", err)
+	}
+
+	if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"0000"}`; got != want {
+		t.Errorf("row = %s, want %s", got, want)
+	}
+	if left, _ := redisClient.ZCard(ctx, holdDeadlinesKey).Result(); left != 0 {
+		t.Errorf("%d deadlines left, want 0", left)
+	}
+}
+
+func TestReleaseHold(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name      string
+		deadline  time.Time
+		expiredAt int64 // 0 for a cancellation
+		wantErr   error
+		wantSeats string
+	}{
+		{name: "cancelled before its deadline", deadline: now.Add(time.Minute), wantSeats: "0000"},
+		{name: "expired hold", deadline: now.Add(-time.Minute), expiredAt: now.Unix(), wantSeats: "0000"},
+		{name: "hold refreshed since the reaper read it", deadline: now.Add(time.Minute), expiredAt: now.Unix(), wantErr: ErrHoldNotFound, wantSeats: "1100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			hold := reserveTestHold(t, s, 2)
+			redisClient.ZAddXX(ctx, holdDeadlinesKey, redislib.Z{Score: float64(tt.deadline.Unix()), Member: getHoldDeadlineMember(testSessionID, hold.ID)})
+
+			if err := s.releaseHold(ctx, testSessionID, hold, tt.expiredAt); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("releaseHold() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"`+tt.wantSeats+`"}`; got != want {
+				t.Errorf("row = %s, want %s", got, want)
+			}
+
+			// Released once only
+			if tt.wantErr == nil {
+				if err := s.releaseHold(ctx, testSessionID, hold, tt.expiredAt); !errors.Is(err, ErrHoldNotFound) {
+					t.Errorf("second releaseHold() error = %v, want %v", err, ErrHoldNotFound)
+				}
+			}
+		})
+	}
+}
+
+func TestClaimHold(t *testing.T) {
+	s, redisClient := newTestTicketService(t)
+	ctx := context.Background()
+	hold := reserveTestHold(t, s, 2)
+	deadlineMember := getHoldDeadlineMember(testSessionID, hold.ID)
+	deadline, _ := redisClient.ZScore(ctx, holdDeadlinesKey, deadlineMember).Result()
+
+	claim, err := s.ClaimHold(ctx, testSessionID, hold.ID)
+	if err != nil {
+		t.Fatalf("ClaimHold() error = %v", err)
+	}
+	if claim.Deadline.Unix() != int64(deadline) {
+		t.Errorf("claim deadline = %v, want %v", claim.Deadline.Unix(), int64(deadline))
+	}
+
+	// The lease replaces the deadline
+	lease, _ := redisClient.ZScore(ctx, holdDeadlinesKey, deadlineMember).Result()
+	if want := time.Now().Add(HoldClaimTTL).Unix(); int64(lease) < want-1 || int64(lease) > want {
+		t.Errorf("lease = %v, want %v", int64(lease), want)
+	}
+	if ttl := redisClient.PTTL(ctx, getHoldClaimKey(testSessionID, hold.ID)).Val(); ttl <= 0 || ttl > HoldClaimTTL {
+		t.Errorf("claim ttl = %v, want up to %v", ttl, HoldClaimTTL)
+	}
+
+	// A claim that is not the current one restores nothing
+	stale := claim
+	stale.token = "stale"
+	if err := s.RestoreHold(ctx, stale); err != nil {
+		t.Fatalf("RestoreHold() error = %v", err)
+	}
+	if got, _ := redisClient.ZScore(ctx, holdDeadlinesKey, deadlineMember).Result(); got != lease {
+		t.Errorf("deadline after a stale restore = %v, want the lease %v", got, lease)
+	}
+
+	if err := s.RestoreHold(ctx, claim); err != nil {
+		t.Fatalf("RestoreHold() error = %v", err)
+	}
+	if got, _ := redisClient.ZScore(ctx, holdDeadlinesKey, deadlineMember).Result(); got != deadline {
+		t.Errorf("restored deadline = %v, want %v", got, deadline)
+	}
+	if _, err := s.ClaimHold(ctx, testSessionID, hold.ID); err != nil {
+		t.Errorf("ClaimHold() after restore error = %v", err)
+	}
+}
+
+func TestClaimHoldExpired(t *testing.T) {
+	tests := []struct {
+		name     string
+		deadline time.Duration // from now
+		wantErr  error
+	}{
+		{name: "hold before its deadline", deadline: time.Minute},
+		{name: "hold at its deadline", deadline: 0, wantErr: ErrHoldNotFound},
+		{name: "hold past its deadline, not released yet", deadline: -time.Hour, wantErr: ErrHoldNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			hold := reserveTestHold(t, s, 2)
+			deadlineMember := getHoldDeadlineMember(testSessionID, hold.ID)
+			redisClient.ZAddXX(ctx, holdDeadlinesKey, redislib.Z{Score: float64(time.Now().Add(tt.deadline).Unix()), Member: deadlineMember})
+
+			if _, err := s.ClaimHold(ctx, testSessionID, hold.ID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ClaimHold() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil && redisClient.Exists(ctx, getHoldClaimKey(testSessionID, hold.ID)).Val() != 0 {
+				t.Error("expired hold was claimed")
+			}
+		})
+	}
+}
+
+func TestMoveHolds(t *testing.T) {
+	const toSessionID = "session-2"
+
+	tests := []struct {
+		name      string
+		claim     bool // the hold is being paid
+		wantMoved bool
+	}{
+		{name: "hold is moved with its deadline", wantMoved: true},
+		{name: "hold being paid stays", claim: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			hold := reserveTestHold(t, s, 2)
+			deadline, _ := redisClient.ZScore(ctx, holdDeadlinesKey, getHoldDeadlineMember(testSessionID, hold.ID)).Result()
+			if tt.claim {
+				if _, err := s.ClaimHold(ctx, testSessionID, hold.ID); err != nil {
+					t.Fatalf("ClaimHold() error = %v", err)
+				}
+			}
+
+			moved, err := s.MoveHolds(ctx, testSessionID, toSessionID)
+			if err != nil {
+				t.Fatalf("MoveHolds() error = %v", err)
+			}
+			if gotMoved := len(moved) == 1 && moved[0] == hold.ID; gotMoved != tt.wantMoved {
+				t.Fatalf("moved = %v, want hold moved: %v", moved, tt.wantMoved)
+			}
+
+			ownerID, otherID := testSessionID, toSessionID
+			if tt.wantMoved {
+				ownerID, otherID = otherID, ownerID
+			}
+			if _, err := s.GetHold(ctx, ownerID, hold.ID); err != nil {
+				t.Errorf("hold of %s: %v", ownerID, err)
+			}
+			if _, err := s.GetHold(ctx, otherID, hold.ID); !errors.Is(err, ErrHoldNotFound) {
+				t.Errorf("hold of %s error = %v, want %v", otherID, err, ErrHoldNotFound)
+			}
+			if err := redisClient.ZScore(ctx, holdDeadlinesKey, getHoldDeadlineMember(otherID, hold.ID)).Err(); err != redislib.Nil {
+				t.Errorf("deadline of %s left behind, err: %v", otherID, err)
+			}
+
+			if tt.wantMoved {
+				if got, _ := redisClient.ZScore(ctx, holdDeadlinesKey, getHoldDeadlineMember(toSessionID, hold.ID)).Result(); got != deadline {
+					t.Errorf("moved deadline = %v, want %v", got, deadline)
+				}
+				if ttl := redisClient.TTL(ctx, getReservationKey(toSessionID)).Val(); ttl <= 0 {
+					t.Errorf("moved holds ttl = %v, want the old one", ttl)
+				}
+			}
+		})
+	}
+}
+
+func TestCancelHold(t *testing.T) {
+	tests := []struct {
+		name      string
+		claim     bool
+		wantErr   error
+		wantSeats string
+	}{
+		{name: "held seats are released", wantSeats: "0000"},
+		{name: "hold being booked cannot be cancelled", claim: true, wantErr: ErrHoldNotFound, wantSeats: "1100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			hold := reserveTestHold(t, s, 2)
+
+			if tt.claim {
+				if _, err := s.ClaimHold(ctx, testSessionID, hold.ID); err != nil {
+					t.Fatalf("ClaimHold() error = %v", err)
+				}
+				// A second claim loses
+				if _, err := s.ClaimHold(ctx, testSessionID, hold.ID); !errors.Is(err, ErrHoldNotFound) {
+					t.Fatalf("second ClaimHold() error = %v, want %v", err, ErrHoldNotFound)
+				}
+			}
+
+			if err := s.CancelHold(ctx, testSessionID, 1, hold.ID); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CancelHold() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"`+tt.wantSeats+`"}`; got != want {
+				t.Errorf("row = %s, want %s", got, want)
+			}
+		})
+	}
+}
+
+func TestGetRefreshedDeadline(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		want      time.Time
+	}{
+		{name: "new hold gets holdTTL", createdAt: now, want: now.Add(holdTTL)},
+		{name: "just under the cap", createdAt: now.Add(holdTTL - holdMaxLifetime), want: now.Add(holdTTL)},
+		{name: "capped at holdMaxLifetime", createdAt: now.Add(-14 * time.Minute), want: now.Add(time.Minute)},
+		{name: "past holdMaxLifetime is not extended", createdAt: now.Add(-20 * time.Minute), want: now.Add(-5 * time.Minute)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := getRefreshedDeadline(now, tt.createdAt.Unix()); !got.Equal(tt.want) {
+				t.Errorf("getRefreshedDeadline() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshHold(t *testing.T) {
+	tests := []struct {
+		name          string
+		eventID       int
+		createdAt     func(now time.Time) string // hash value of the hold
+		claim         bool
+		wantErr       error
+		wantDeadline  func(now time.Time) time.Time
+		wantCreatedAt func(now time.Time) string
+	}{
+		{
+			name:         "new hold gets holdTTL from now",
+			eventID:      1,
+			createdAt:    func(now time.Time) string { return strconv.FormatInt(now.Unix(), 10) },
+			wantDeadline: func(now time.Time) time.Time { return now.Add(holdTTL) },
+		},
+		{
+			name:         "old hold is capped at holdMaxLifetime",
+			eventID:      1,
+			createdAt:    func(now time.Time) string { return strconv.FormatInt(now.Add(-14*time.Minute).Unix(), 10) },
+			wantDeadline: func(now time.Time) time.Time { return now.Add(time.Minute) },
+		},
+		{
+			name:          "unparseable creation time starts the lifetime now",
+			eventID:       1,
+			createdAt:     func(now time.Time) string { return "reserved" },
+			wantDeadline:  func(now time.Time) time.Time { return now.Add(holdTTL) },
+			wantCreatedAt: func(now time.Time) string { return strconv.FormatInt(now.Unix(), 10) },
+		},
+		{
+			name:      "claimed hold is not refreshed",
+			eventID:   1,
+			createdAt: func(now time.Time) string { return strconv.FormatInt(now.Unix(), 10) },
+			claim:     true,
+			wantErr:   ErrHoldNotFound,
+		},
+		{
+			name:      "hold of another event",
+			eventID:   2,
+			createdAt: func(now time.Time) string { return strconv.FormatInt(now.Unix(), 10) },
+			wantErr:   ErrHoldNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			ctx := context.Background()
+			hold := reserveTestHold(t, s, 2)
+			reservationKey := getReservationKey(testSessionID)
+			deadlineMember := getHoldDeadlineMember(testSessionID, hold.ID)
+
+			now := time.Now()
+			redisClient.HSet(ctx, reservationKey, hold.ID, tt.createdAt(now))
+			if tt.claim {
+				if _, err := s.ClaimHold(ctx, testSessionID, hold.ID); err != nil {
+					t.Fatalf("ClaimHold() error = %v", err)
+				}
+			}
+			before, _ := redisClient.ZScore(ctx, holdDeadlinesKey, deadlineMember).Result()
+
+			deadline, err := s.RefreshHold(ctx, testSessionID, tt.eventID, hold.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RefreshHold() error = %v, want %v", err, tt.wantErr)
+			}
+
+			score, scoreErr := redisClient.ZScore(ctx, holdDeadlinesKey, deadlineMember).Result()
+			if tt.claim {
+				// The lease is left alone
+				if scoreErr != nil || score != before {
+					t.Errorf("claimed hold deadline = %v, want the lease %v (err: %v)", score, before, scoreErr)
+				}
+				return
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			// Deadlines are stored in whole seconds and the clock may tick before the refresh
+			if want := tt.wantDeadline(now); deadline.Sub(want) < -time.Second || deadline.Sub(want) > time.Second {
+				t.Errorf("deadline = %v, want %v", deadline, want)
+			}
+			if int64(score) != deadline.Unix() {
+				t.Errorf("deadline score = %v, want %v", int64(score), deadline.Unix())
+			}
+			if tt.wantCreatedAt != nil {
+				createdAt, _ := redisClient.HGet(ctx, reservationKey, hold.ID).Result()
+				if createdAt != tt.wantCreatedAt(now) && createdAt != tt.wantCreatedAt(now.Add(time.Second)) {
+					t.Errorf("created at = %s, want %s", createdAt, tt.wantCreatedAt(now))
+				}
+			}
+		})
+	}
+}
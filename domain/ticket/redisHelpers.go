@@ -165,6 +165,66 @@ func cacheSeatPriceBlocks(ctx context.Context, tx *redislib.Tx, eventID, section
 	return getSeatPriceBlocks(ctx, tx, eventID, sectionID, lowPrice, highPrice)
 }
 
+func getViewRanksKey(eventID int) string {
+	return fmt.Sprintf("event:%d:view_ranks", eventID)
+}
+
+// getCachedViewRanks reads event:{event_id}:view_ranks, fields section:{section_id} and row:{row_id} hold the ranks
+func getCachedViewRanks(ctx context.Context, tx *redislib.Tx, eventID int) (venue.ViewRanks, error) {
+	fields, err := tx.HGetAll(ctx, getViewRanksKey(eventID)).Result()
+	if err != nil {
+		return venue.ViewRanks{}, fmt.Errorf("failed to get view ranks from cache: %w", err)
+	}
+
+	viewRanks := venue.ViewRanks{Sections: make(map[int]int), Rows: make(map[int]int)}
+	for field, value := range fields {
+		kind, idStr, _ := strings.Cut(field, ":")
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			return venue.ViewRanks{}, fmt.Errorf("invalid view rank field %s", field)
+		}
+		rank, err := strconv.Atoi(value)
+		if err != nil {
+			return venue.ViewRanks{}, fmt.Errorf("failed to parse view rank of %s: %w", field, err)
+		}
+
+		switch kind {
+		case "section":
+			viewRanks.Sections[id] = rank
+		case "row":
+			viewRanks.Rows[id] = rank
+		default:
+			return venue.ViewRanks{}, fmt.Errorf("invalid view rank field %s", field)
+		}
+	}
+
+	return viewRanks, nil
+}
+
+// Load the view ranks of the sections and rows of the venue of an event
+func cacheViewRanks(ctx context.Context, tx *redislib.Tx, eventID int, venueService *venue.VenueService) (venue.ViewRanks, error) {
+	viewRanks, err := venueService.GetViewRanks(eventID)
+	if err != nil {
+		return venue.ViewRanks{}, err
+	}
+	if len(viewRanks.Rows) == 0 {
+		return viewRanks, nil
+	}
+
+	fields := make(map[string]interface{}, len(viewRanks.Sections)+len(viewRanks.Rows))
+	for sectionID, rank := range viewRanks.Sections {
+		fields[fmt.Sprintf("section:%d", sectionID)] = rank
+	}
+	for rowID, rank := range viewRanks.Rows {
+		fields[fmt.Sprintf("row:%d", rowID)] = rank
+	}
+	if err := tx.HSet(ctx, getViewRanksKey(eventID), fields).Err(); err != nil {
+		return venue.ViewRanks{}, fmt.Errorf("failed to cache view ranks: %w", err)
+	}
+
+	return viewRanks, nil
+}
+
 func getConsecutiveSeats(ctx context.Context,
 	tx *redislib.Tx, eventID, sectionID int,
 	block *venue.SeatPriceBlock) (string, error) {
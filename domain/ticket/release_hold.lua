@@ -0,0 +1,94 @@
+-- Keys
+local seatsKey = KEYS[1]         -- event:{event_id}:section:{section_id}:rows
+local priceBlocksKey = KEYS[2]   -- event:{event_id}:section:{section_id}:price_blocks
+local reservationKey = KEYS[3]   -- session:{session_id}:reservations
+local holdDeadlinesKey = KEYS[4] -- hold_deadlines
+local claimKey = KEYS[5]         -- hold_claim:{session_id}|{hold_id}
+
+-- Get input arguments
+local rowID = ARGV[1]
+local startSeatNumber = tonumber(ARGV[2])
+local length = tonumber(ARGV[3])
+local holdID = ARGV[4]
+local deadlineMember = ARGV[5]   -- {session_id}|{hold_id}
+local now = tonumber(ARGV[6])    -- unix seconds
+local onlyExpired = ARGV[7] == "1"
+
+-- A hold claimed for booking keeps its seats until the lease runs out
+if redis.call("EXISTS", claimKey) == 1 then
+    return false
+end
+
+-- A hold refreshed since the reaper read it is kept
+if onlyExpired then
+    local deadline = redis.call("ZSCORE", holdDeadlinesKey, deadlineMember)
+    if deadline and tonumber(deadline) > now then
+        return false
+    end
+end
+
+-- Whoever removes the deadline releases the hold, a hold booked
+-- or released by another instance has none anymore
+if redis.call("ZREM", holdDeadlinesKey, deadlineMember) == 0 then
+    return false
+end
+
+redis.call("HDEL", reservationKey, holdID)
+
+-- The row is not cached anymore, it will be rebuilt from the DB on next read
+local rowData = redis.call("HGET", seatsKey, rowID)
+if not rowData then
+    return {}
+end
+
+-- Put the seats back on sale
+local rowInfo = cjson.decode(rowData)
+local seats = rowInfo.seats
+local seatCount = #seats
+local endSeatNumber = math.min(startSeatNumber + length - 1, seatCount)
+if startSeatNumber <= endSeatNumber then
+    seats = seats:sub(1, startSeatNumber - 1) .. string.rep("0", endSeatNumber - startSeatNumber + 1) .. seats:sub(endSeatNumber + 1)
+end
+
+rowInfo.seats = seats
+redis.call("HSET", seatsKey, rowID, cjson.encode(rowInfo))
+
+-- Calculate max consecutive lengths for each price in the row, same as the booking script
+local priceMaxConsecutive = {}
+
+local priceBlocks = redis.call("ZRANGE", priceBlocksKey, 0, -1, "WITHSCORES")
+for i = 1, #priceBlocks, 2 do
+    local block = priceBlocks[i]
+    local price = priceBlocks[i + 1]
+
+    -- Member format: {row_id}:{start_seat_id}:{start_seat_number}:{end_seat_id}:{end_seat_number}
+    local blockRowID, _, startNumber, _, endNumber = block:match("^(%d+):(%d+):(%d+):(%d+):(%d+)$")
+    if blockRowID == rowID then
+        local maxLength = 0
+        local currentLength = 0
+
+        for j = tonumber(startNumber), math.min(tonumber(endNumber), seatCount) do
+            if seats:sub(j, j) == "0" then
+                currentLength = currentLength + 1
+                if currentLength > maxLength then
+                    maxLength = currentLength
+                end
+            else
+                currentLength = 0
+            end
+        end
+
+        if priceMaxConsecutive[price] == nil or maxLength > priceMaxConsecutive[price] then
+            priceMaxConsecutive[price] = maxLength
+        end
+    end
+end
+
+-- Flat array: {price, max_length, price, max_length, ...}
+local result = {}
+for price, maxLength in pairs(priceMaxConsecutive) do
+    table.insert(result, tonumber(price))
+    table.insert(result, maxLength)
+end
+
+return result
@@ -0,0 +1,147 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"ticket-booking-backend/dto"
+	"time"
+
+	redislib "github.com/redis/go-redis/v9"
+)
+
+// ErrRequestNotFound is returned for unknown, expired or foreign reservation requests
+var ErrRequestNotFound = errors.New("reservation request not found")
+
+// reservationRequestTTL bounds how long clients can poll a request, it outlives the hold
+const reservationRequestTTL = 10 * time.Minute
+
+func getReservationRequestKey(requestID string) string {
+	return fmt.Sprintf("reservation_request:%s", requestID)
+}
+
+// getReservationAppliedKey records the holds of a reservation request in the same write as the holds,
+// so a retry after a timeout returns them instead of reserving the seats a second time
+func getReservationAppliedKey(requestID string) string {
+	return fmt.Sprintf("reservation_applied:%s", requestID)
+}
+
+// appliedReservation is the value of getReservationAppliedKey
+type appliedReservation struct {
+	HoldIDs []string `json:"hold_ids"`
+	Price   int      `json:"price"`
+}
+
+// errReservationApplied is raised by the booking script for a request applied by a previous attempt
+var errReservationApplied = errors.New("reservation request already applied")
+
+// setAppliedReservation records the holds of the request along with them, requests without ID are not recorded
+func setAppliedReservation(ctx context.Context, pipe redislib.Pipeliner, requestID string, result reservationResult) error {
+	if requestID == "" {
+		return nil
+	}
+
+	applied := appliedReservation{Price: result.Price}
+	for _, hold := range result.Holds {
+		applied.HoldIDs = append(applied.HoldIDs, hold.ID)
+	}
+	data, err := json.Marshal(applied)
+	if err != nil {
+		return fmt.Errorf("failed to encode applied reservation: %w", err)
+	}
+
+	pipe.Set(ctx, getReservationAppliedKey(requestID), data, reservationRequestTTL)
+	return nil
+}
+
+// getAppliedReservation returns the holds of a request applied by a previous attempt.
+// With a transaction, the record is watched: an attempt still in flight aborts the transaction when it lands.
+func getAppliedReservation(ctx context.Context, client redislib.Cmdable, requestID string) (reservationResult, bool, error) {
+	if requestID == "" {
+		return reservationResult{}, false, nil
+	}
+
+	key := getReservationAppliedKey(requestID)
+	if tx, ok := client.(*redislib.Tx); ok {
+		if err := tx.Watch(ctx, key).Err(); err != nil {
+			return reservationResult{}, false, fmt.Errorf("failed to watch applied reservation: %w", err)
+		}
+	}
+
+	data, err := client.Get(ctx, key).Result()
+	if err == redislib.Nil {
+		return reservationResult{}, false, nil
+	} else if err != nil {
+		return reservationResult{}, false, fmt.Errorf("failed to get applied reservation: %w", err)
+	}
+
+	var applied appliedReservation
+	if err := json.Unmarshal([]byte(data), &applied); err != nil {
+		return reservationResult{}, false, fmt.Errorf("failed to decode applied reservation: %w", err)
+	}
+
+	// The availability was broadcast, or lost, by the attempt that applied it
+	result := reservationResult{Price: applied.Price}
+	for _, holdID := range applied.HoldIDs {
+		hold, err := ParseHoldID(holdID)
+		if err != nil {
+			return reservationResult{}, false, err
+		}
+		result.Holds = append(result.Holds, hold)
+	}
+	return result, true, nil
+}
+
+func (s *TicketService) setReservationRequest(ctx context.Context, request ReservationRequest) error {
+	data, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("failed to encode reservation request: %w", err)
+	}
+
+	if err := s.redisClient.Set(ctx, getReservationRequestKey(request.RequestID), data, reservationRequestTTL).Err(); err != nil {
+		return fmt.Errorf("failed to save reservation request: %w", err)
+	}
+	return nil
+}
+
+func (s *TicketService) updateReservationRequest(msg dto.ReservationMsg, status, reason, message string, holdIDs ...string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	request := ReservationRequest{
+		RequestID: msg.RequestID,
+		SessionID: msg.SessionID,
+		EventID:   msg.EventID,
+		Status:    status,
+		Reason:    reason,
+		Message:   message,
+		HoldIDs:   holdIDs,
+	}
+	if len(holdIDs) > 0 {
+		request.HoldID = holdIDs[0]
+	}
+	return s.setReservationRequest(ctx, request)
+}
+
+// GetReservationRequest returns the status of a reservation request made by the session
+func (s *TicketService) GetReservationRequest(ctx context.Context, sessionID, requestID string) (ReservationRequest, error) {
+	data, err := s.redisClient.Get(ctx, getReservationRequestKey(requestID)).Result()
+	if err == redislib.Nil {
+		return ReservationRequest{}, ErrRequestNotFound
+	} else if err != nil {
+		return ReservationRequest{}, fmt.Errorf("failed to get reservation request: %w", err)
+	}
+
+	var request ReservationRequest
+	if err := json.Unmarshal([]byte(data), &request); err != nil {
+		return ReservationRequest{}, fmt.Errorf("failed to decode reservation request: %w", err)
+	}
+
+	// Do not reveal requests of other sessions
+	if request.SessionID != sessionID {
+		return ReservationRequest{}, ErrRequestNotFound
+	}
+
+	return request, nil
+}
@@ -1,130 +1,145 @@
--- Get input arguments
-local eventID = ARGV[1]
-local sectionID = ARGV[2]
-local rowID = ARGV[3]
-local length = tonumber(ARGV[4])
-local sessionID = ARGV[5] -- for tracking user session
+-- Keys
+local seatsKey = KEYS[1]         -- event:{event_id}:section:{section_id}:rows
+local priceBlocksKey = KEYS[2]   -- event:{event_id}:section:{section_id}:price_blocks
+local reservationKey = KEYS[3]   -- session:{session_id}:reservations
+local holdDeadlinesKey = KEYS[4] -- hold_deadlines
+local appliedKey = KEYS[5]       -- reservation_applied:{request_id}
 
--- Key for seat availability
-local seatsKey = "event:" .. eventID .. ":section:" .. sectionID .. ":rows"
+-- Get input arguments
+local rowID = ARGV[1]
+local length = tonumber(ARGV[2])
+local holdPrefix = ARGV[3]          -- {event_id}:{section_id}:{row_id}
+local holdTTL = tonumber(ARGV[4])   -- seconds
+local deadline = ARGV[5]            -- unix seconds
+local sessionID = ARGV[6]           -- for tracking user session
+local createdAt = ARGV[7]           -- unix seconds
+local price = ARGV[8]               -- only seats of the price blocks of this price can be reserved
+local requestID = ARGV[9]           -- empty when the request is not recorded
+local appliedTTL = ARGV[10]         -- seconds
+
+-- A previous attempt that timed out may have applied the request, do not reserve twice
+if requestID ~= "" and redis.call("EXISTS", appliedKey) == 1 then
+    return redis.error_reply("reservation request already applied")
+end
 
 -- Get the row data
 local rowData = redis.call("HGET", seatsKey, rowID)
 if not rowData then
-    return {err = "Row not found"}
+    return redis.error_reply("row not found")
 end
 
 local rowInfo = cjson.decode(rowData)
 local seats = rowInfo.seats
 
-local reservedSeats = {} -- Empty table, can be used as array, dict, ...
 local seatCount = #seats -- # Get the length of string
 local consecutiveCount = 0
-local maxConsecutive = 0
+local startSeatNumber = 0
+
+-- Seats of the row sold at the requested price
+-- Member format: {row_id}:{start_seat_id}:{start_seat_number}:{end_seat_id}:{end_seat_number}
+local atPrice = {}
+local priceFound = false
+for _, block in ipairs(redis.call("ZRANGEBYSCORE", priceBlocksKey, price, price)) do
+    local blockRowID, _, startNumber, _, endNumber = block:match("^(%d+):(%d+):(%d+):(%d+):(%d+)$")
+    if blockRowID == rowID then
+        priceFound = true
+        for j = tonumber(startNumber), tonumber(endNumber) do
+            atPrice[j] = true
+        end
+    end
+end
 
--- Mark the reserved seats 
+if not priceFound then
+    return redis.error_reply("seat is not sold at this price")
+end
+
+-- Find the first run of available seats at the price, seat numbers are 1-based string positions
 for i = 1, seatCount do
     local seat = seats:sub(i, i)
-    if seat == "0" then -- Available 
+    if seat == "0" and atPrice[i] then -- Available
         consecutiveCount = consecutiveCount + 1
     else
-        consecutiveCount = 0 -- Reset 
+        consecutiveCount = 0 -- Reset
     end
 
-    -- Reserve the seat if enough consecutive seats are found
-    -- Marks backwards
     if consecutiveCount == length then
-        for j = 1, length do
-            reservedSeats[i - j + 1] = true -- Mark reserved seats
-        end
+        startSeatNumber = i - length + 1
         break
     end
 end
 
 -- Check if we found enough consecutive seats
-if next(reservedSeats) == nil then -- next() used to iterate table, it table is empty it returns nil
-    return {err = "Not enough consecutive seats available"}
+if startSeatNumber == 0 then
+    return redis.error_reply("not enough consecutive seats available")
 end
 
 -- Reserve the seats
-for index, _ in pairs(reservedSeats) do -- pairs() used to iterate over all key-value pairs in the table
-    seats = seats:sub(1, index - 1) .. "1" .. seats:sub(index + 1) -- Mark seat as reserved
-end
+seats = seats:sub(1, startSeatNumber - 1) .. string.rep("1", length) .. seats:sub(startSeatNumber + length)
 
 -- Update the row data
 rowInfo.seats = seats
 redis.call("HSET", seatsKey, rowID, cjson.encode(rowInfo))
 
--- ======================================================== 
+-- Add the hold to the session, same layout as setReservation
+local holdID = holdPrefix .. ":" .. startSeatNumber .. ":" .. length
+redis.call("HSET", reservationKey, holdID, createdAt)
+-- Only ever extend the expiration, a refreshed hold of the session may need longer than holdTTL
+redis.call("EXPIRE", reservationKey, holdTTL, "NX")
+redis.call("EXPIRE", reservationKey, holdTTL, "GT")
+redis.call("ZADD", holdDeadlinesKey, deadline, sessionID .. "|" .. holdID)
+
+-- Record the request along with the hold, same layout as setAppliedReservation
+if requestID ~= "" then
+    redis.call("SET", appliedKey, cjson.encode({hold_ids = {holdID}, price = tonumber(price)}), "EX", appliedTTL)
+end
+
+-- ========================================================
 
--- Calculate max consecutive lengths for each price block
-local priceBlocksKey = "event:" .. eventID .. ":section:" .. sectionID .. ":price_blocks"
+-- Calculate max consecutive lengths for each price in the row
 local priceMaxConsecutive = {}
 
 local priceBlocks = redis.call("ZRANGE", priceBlocksKey, 0, -1, "WITHSCORES")
 -- return odd: member, even:score
 
--- Filter price blocks by rowID
-local filteredPriceBlocks = {}
-
 for i = 1, #priceBlocks, 2 do -- 2 -> i += 2
     local block = priceBlocks[i] -- member
-    local price = tonumber(priceBlocks[i + 1]) -- score
-
-     -- Check if the block's row_id matches the given rowID
-    local currentRowID = block:match("^(%d+):") -- Extract row_id from block
-    if currentRowID == rowID then
-        table.insert(filteredPriceBlocks, block)
-        table.insert(filteredPriceBlocks, price) -- Add the corresponding price
-    end
-end
-
--- Initialize priceMaxConsecutive with zero lengths for filtered blocks
-for i = 1, #filteredPriceBlocks, 2 do
-    local block = filteredPriceBlocks[i]
-    local price = tonumber(filteredPriceBlocks[i + 1])
-    priceMaxConsecutive[block] = 0 -- Start with 0 for each price block
-end
-
--- Check availability in the row for each filtered price block
-for i = 1, #filteredPriceBlocks, 2 do
-    local block = filteredPriceBlocks[i]
-    local price = tonumber(filteredPriceBlocks[i + 1])
-
-    -- Get start and end seat IDs and numbers from the block
-    local start_seat_id, start_seat_number, end_seat_id, end_seat_number = block:match(":(%d+):(%d+):(%d+):(%d+)")
-    start_seat_number = tonumber(start_seat_number)
-    end_seat_number = tonumber(end_seat_number)
-
-    -- Check availability within the defined range of seats
-    local maxLength = 0
-    local currentLength = 0
-
-    for j = start_seat_number, end_seat_number do
-        local seat = seats:sub(j, j)
-        if seat == "0" then -- Available seat
-            currentLength = currentLength + 1
-        else
-            if currentLength > maxLength then
-                maxLength = currentLength
+    local price = priceBlocks[i + 1] -- score
+
+    -- Member format: {row_id}:{start_seat_id}:{start_seat_number}:{end_seat_id}:{end_seat_number}
+    local blockRowID, _, startNumber, _, endNumber = block:match("^(%d+):(%d+):(%d+):(%d+):(%d+)$")
+    if blockRowID == rowID then
+        local maxLength = 0
+        local currentLength = 0
+
+        for j = tonumber(startNumber), math.min(tonumber(endNumber), seatCount) do
+            if seats:sub(j, j) == "0" then -- Available seat
+                currentLength = currentLength + 1
+            else
+                if currentLength > maxLength then
+                    maxLength = currentLength
+                end
+                currentLength = 0
             end
-            currentLength = 0
         end
-    end
 
-    -- Final check for the last segment
-    if currentLength > maxLength then
-        maxLength = currentLength
-    end
+        -- Final check for the last segment
+        if currentLength > maxLength then
+            maxLength = currentLength
+        end
 
-    -- Update max length for the current price block
-    priceMaxConsecutive[block] = maxLength
+        -- A price can span several blocks in the same row
+        if priceMaxConsecutive[price] == nil or maxLength > priceMaxConsecutive[price] then
+            priceMaxConsecutive[price] = maxLength
+        end
+    end
 end
 
--- Filter to only keep the maximum lengths for each price
-local finalMaxConsecutive = {}
-for price, length in pairs(priceMaxConsecutive) do
-    finalMaxConsecutive[price] = length
+-- Lua tables with string keys do not survive the conversion to a redis reply,
+-- so return a flat array: {start_seat_number, charged_price, price, max_length, price, max_length, ...}
+local result = {startSeatNumber, tonumber(price)}
+for price, maxLength in pairs(priceMaxConsecutive) do
+    table.insert(result, tonumber(price))
+    table.insert(result, maxLength)
 end
 
-return {reserved = reservedSeats, maxConsecutive = finalMaxConsecutive}
+return result
@@ -0,0 +1,18 @@
+-- Keys
+local holdDeadlinesKey = KEYS[1] -- hold_deadlines
+local claimKey = KEYS[2]         -- hold_claim:{session_id}|{hold_id}
+
+-- Get input arguments
+local token = ARGV[1]
+local deadlineMember = ARGV[2]   -- {session_id}|{hold_id}
+local deadline = ARGV[3]         -- unix seconds, the deadline before the claim
+
+-- A lease that ran out may have been released or claimed again, leave it alone
+if redis.call("GET", claimKey) ~= token then
+    return 0
+end
+
+redis.call("DEL", claimKey)
+redis.call("ZADD", holdDeadlinesKey, "XX", deadline, deadlineMember)
+
+return 1
@@ -0,0 +1,91 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"ticket-booking-backend/dto"
+	"time"
+
+	redislib "github.com/redis/go-redis/v9"
+)
+
+var (
+	bookingMaxAttempts    = 5
+	bookingAttemptTimeout = 2 * time.Second
+	bookingBaseBackoff    = 20 * time.Millisecond
+	bookingMaxBackoff     = 500 * time.Millisecond
+)
+
+// reserveSeatsWithRetry retries retryable failures with exponential backoff and full jitter,
+// terminal failures are returned right away.
+func (s *TicketService) reserveSeatsWithRetry(msg dto.ReservationMsg) (reservationResult, error) {
+	var result reservationResult
+	var err error
+
+	for attempt := 1; attempt <= bookingMaxAttempts; attempt++ {
+		result, err = s.reserveSeatsOnce(msg)
+		if err == nil || !isRetryableBookingErr(err) {
+			return result, err
+		}
+
+		if attempt == bookingMaxAttempts {
+			break
+		}
+
+		sleep := time.Duration(rand.Int63n(int64(bookingBackoff(attempt))) + 1)
+
+		log.Printf("reservation attempt %d for session %s failed: %v, retrying in %v", attempt, msg.SessionID, err, sleep)
+		time.Sleep(sleep)
+	}
+
+	return result, err
+}
+
+// bookingBackoff is the upper bound of the sleep after a failed attempt, doubling up to bookingMaxBackoff
+func bookingBackoff(attempt int) time.Duration {
+	backoff := bookingBaseBackoff << (attempt - 1)
+	if backoff > bookingMaxBackoff || backoff <= 0 { // <= 0 once shifted past the int64 range
+		return bookingMaxBackoff
+	}
+	return backoff
+}
+
+func (s *TicketService) reserveSeatsOnce(msg dto.ReservationMsg) (reservationResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bookingAttemptTimeout)
+	defer cancel()
+
+	// Best available and specific seats read several rows, only the WATCH transaction does that
+	switch msg.Mode {
+	case dto.ReservationModeBestAvailable:
+		return s.reserveBestAvailable(ctx, msg)
+	case dto.ReservationModeSeats:
+		return s.reserveSpecificSeats(ctx, msg)
+	}
+
+	switch s.bookingEngine {
+	case bookingEngineGo:
+		return s.reserveSeats(ctx, msg)
+	default:
+		return s.reserveSeatsLua(ctx, msg)
+	}
+}
+
+// isRetryableBookingErr reports whether a reservation failure is transient:
+// a WATCH conflict or a timeout talking to redis.
+// A timed out attempt may still have been applied, every engine records the request ID
+// along with the holds so the retry returns them instead of reserving twice, see getAppliedReservation.
+func isRetryableBookingErr(err error) bool {
+	if errors.Is(err, redislib.TxFailedErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return false
+}
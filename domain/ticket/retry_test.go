@@ -0,0 +1,135 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"ticket-booking-backend/dto"
+
+	redislib "github.com/redis/go-redis/v9"
+)
+
+// timeoutErr is a net.Error, like the errors of a redis connection
+type timeoutErr struct{ timeout bool }
+
+func (e timeoutErr) Error() string   { return "i/o error" }
+func (e timeoutErr) Timeout() bool   { return e.timeout }
+func (e timeoutErr) Temporary() bool { return false }
+
+var _ net.Error = timeoutErr{}
+
+func TestIsRetryableBookingErr(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "watch conflict", err: redislib.TxFailedErr, want: true},
+		{name: "wrapped watch conflict", err: fmt.Errorf("failed to update seats data: %w", redislib.TxFailedErr), want: true},
+		{name: "attempt timeout", err: context.DeadlineExceeded, want: true},
+		{name: "network timeout", err: timeoutErr{timeout: true}, want: true},
+		{name: "wrapped network timeout", err: fmt.Errorf("failed to get row data: %w", timeoutErr{timeout: true}), want: true},
+		{name: "network error", err: timeoutErr{}, want: false},
+		{name: "cancelled", err: context.Canceled, want: false},
+		{name: "row not found", err: ErrRowNotFound, want: false},
+		{name: "not enough seats", err: ErrNotEnoughSeats, want: false},
+		{name: "seat unavailable", err: ErrSeatUnavailable, want: false},
+		{name: "price mismatch", err: ErrSeatPriceMismatch, want: false},
+		{name: "other", err: errors.New("boom"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRetryableBookingErr(tt.err); got != tt.want {
+				t.Errorf("isRetryableBookingErr(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBookingBackoff(t *testing.T) {
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{attempt: 1, want: 20 * time.Millisecond},
+		{attempt: 2, want: 40 * time.Millisecond},
+		{attempt: 3, want: 80 * time.Millisecond},
+		{attempt: 5, want: 320 * time.Millisecond},
+		{attempt: 6, want: bookingMaxBackoff},
+		{attempt: 100, want: bookingMaxBackoff},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("attempt %d", tt.attempt), func(t *testing.T) {
+			if got := bookingBackoff(tt.attempt); got != tt.want {
+				t.Errorf("bookingBackoff(%d) = %v, want %v", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReserveSeatsOnceAppliedRequest(t *testing.T) {
+	rowMsg := dto.ReservationMsg{RequestID: "request-1", EventID: 1, SectionID: 2, RowID: 3, Price: 100, Length: 2, SessionID: testSessionID}
+	seatsMsg := dto.ReservationMsg{
+		Mode: dto.ReservationModeSeats, RequestID: "request-1", EventID: 1, Price: 100, Length: 2, SessionID: testSessionID,
+		Seats: []dto.SeatRef{{SectionID: 2, RowID: 3, SeatNumber: 2}, {SectionID: 2, RowID: 3, SeatNumber: 3}},
+	}
+
+	tests := []struct {
+		name      string
+		engine    string
+		msg       dto.ReservationMsg
+		wantSeats string
+	}{
+		{name: "lua engine", engine: bookingEngineLua, msg: rowMsg, wantSeats: "1100"},
+		{name: "go engine", engine: bookingEngineGo, msg: rowMsg, wantSeats: "1100"},
+		{name: "specific seats", msg: seatsMsg, wantSeats: "0110"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, redisClient := newTestTicketService(t)
+			s.bookingEngine = tt.engine
+
+			first, err := s.reserveSeatsOnce(tt.msg)
+			if err != nil {
+				t.Fatalf("reserveSeatsOnce() error = %v", err)
+			}
+
+			// The retry of an attempt that timed out after being applied
+			retried, err := s.reserveSeatsOnce(tt.msg)
+			if err != nil {
+				t.Fatalf("retried reserveSeatsOnce() error = %v", err)
+			}
+			if len(retried.Holds) != len(first.Holds) || retried.Holds[0] != first.Holds[0] || retried.Price != first.Price {
+				t.Errorf("retry = %+v, want the holds of the first attempt %+v", retried, first)
+			}
+
+			if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"`+tt.wantSeats+`"}`; got != want {
+				t.Errorf("row = %s, want %s", got, want)
+			}
+			if holds, _ := s.GetHolds(context.Background(), testSessionID, 1); len(holds) != 1 {
+				t.Errorf("holds = %+v, want one", holds)
+			}
+
+			// Another request reserves again
+			other := tt.msg
+			other.RequestID = "request-2"
+			if tt.msg.Mode == dto.ReservationModeSeats {
+				other.Seats = []dto.SeatRef{{SectionID: 2, RowID: 3, SeatNumber: 4}}
+				other.Length = 1
+			}
+			if _, err := s.reserveSeatsOnce(other); err != nil {
+				t.Fatalf("reserveSeatsOnce() of another request error = %v", err)
+			}
+			if holds, _ := s.GetHolds(context.Background(), testSessionID, 1); len(holds) != 2 {
+				t.Errorf("holds = %+v, want two", holds)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ticket
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+// Orders of a ticket search
+const (
+	SortPriceAsc  = "price_asc" // default
+	SortPriceDesc = "price_desc"
+	SortBestView  = "best_view" // sections, then rows, by view rank, see venue.Section
+	SortLength    = "length"    // longest runs first
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ticketCursor is the last result of a page, the next page starts right after it in the search order.
+// Unlike an offset it does not skip or repeat results when seats are taken between two pages.
+type ticketCursor struct {
+	Sort            string `json:"o"`
+	Price           int    `json:"p"`
+	SectionID       int    `json:"s"`
+	RowID           int    `json:"r"`
+	SectionRank     int    `json:"sr,omitempty"`
+	RowRank         int    `json:"rr,omitempty"`
+	StartSeatNumber int    `json:"n"`
+	Length          int    `json:"l"`
+}
+
+func encodeCursor(sortBy string, run seatRun) string {
+	data, _ := json.Marshal(ticketCursor{
+		Sort:            sortBy,
+		Price:           run.Price,
+		SectionID:       run.SectionID,
+		RowID:           run.RowID,
+		SectionRank:     run.SectionRank,
+		RowRank:         run.RowRank,
+		StartSeatNumber: run.StartSeatNumber,
+		Length:          run.Length,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(sortBy, cursor string) (seatRun, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return seatRun{}, ErrInvalidCursor
+	}
+	var c ticketCursor
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sortBy {
+		return seatRun{}, ErrInvalidCursor
+	}
+	return seatRun{
+		SectionID:       c.SectionID,
+		RowID:           c.RowID,
+		SectionRank:     c.SectionRank,
+		RowRank:         c.RowRank,
+		Price:           c.Price,
+		StartSeatNumber: c.StartSeatNumber,
+		Length:          c.Length,
+	}, nil
+}
+
+// compareRuns orders runs by sortBy, ties are broken by price, section, row and seat number so the order is total
+func compareRuns(left, right seatRun, sortBy string) int {
+	var primary []int
+	switch sortBy {
+	case SortPriceDesc:
+		primary = []int{right.Price - left.Price}
+	case SortBestView:
+		primary = []int{left.SectionRank - right.SectionRank, left.RowRank - right.RowRank}
+	case SortLength:
+		primary = []int{right.Length - left.Length}
+	}
+
+	for _, diff := range append(primary,
+		left.Price-right.Price,
+		left.SectionID-right.SectionID,
+		left.RowID-right.RowID,
+		left.StartSeatNumber-right.StartSeatNumber,
+		left.Length-right.Length) {
+		if diff != 0 {
+			return diff
+		}
+	}
+	return 0
+}
+
+// pageRuns sorts the runs and returns the page after the cursor, or at page when there is no cursor,
+// with the cursor of the next page, empty on the last page
+func pageRuns(runs []seatRun, search TicketSearch) ([]seatRun, string, error) {
+	sortBy := search.Sort
+	if sortBy == "" {
+		sortBy = SortPriceAsc
+	}
+	sort.Slice(runs, func(i, j int) bool {
+		return compareRuns(runs[i], runs[j], sortBy) < 0
+	})
+
+	start := (search.Page - 1) * search.PageSize
+	if search.Cursor != "" {
+		after, err := decodeCursor(sortBy, search.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		start = sort.Search(len(runs), func(i int) bool {
+			return compareRuns(runs[i], after, sortBy) > 0
+		})
+	}
+	if start < 0 || start >= len(runs) {
+		return nil, "", nil
+	}
+
+	end := min(start+search.PageSize, len(runs))
+	nextCursor := ""
+	if end < len(runs) {
+		nextCursor = encodeCursor(sortBy, runs[end-1])
+	}
+	return runs[start:end], nextCursor, nil
+}
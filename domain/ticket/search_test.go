@@ -0,0 +1,239 @@
+package ticket
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func run(sectionID, rowID, price, startSeatNumber, length int) seatRun {
+	return seatRun{SectionID: sectionID, RowID: rowID, Price: price, StartSeatNumber: startSeatNumber, Length: length}
+}
+
+// ranked sets the view ranks of the section and the row of a run
+func ranked(r seatRun, sectionRank, rowRank int) seatRun {
+	r.SectionRank, r.RowRank = sectionRank, rowRank
+	return r
+}
+
+// testRuns is shuffled, pageRuns sorts it
+func testRuns() []seatRun {
+	return []seatRun{
+		run(2, 20, 100, 1, 2),
+		run(1, 10, 300, 5, 1),
+		run(1, 11, 100, 1, 4),
+		run(1, 10, 100, 3, 3),
+		run(2, 21, 200, 2, 6),
+	}
+}
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		sortBy string
+		run    seatRun
+	}{
+		{name: "price asc", sortBy: SortPriceAsc, run: run(1, 10, 100, 3, 3)},
+		{name: "price desc", sortBy: SortPriceDesc, run: run(2, 21, 200, 2, 6)},
+		{name: "best view", sortBy: SortBestView, run: ranked(run(7, 70, 0, 1, 1), 2, 3)},
+		{name: "length", sortBy: SortLength, run: run(1, 11, 100, 12, 4)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withSeats := tt.run
+			withSeats.Seats = "0000" // not part of the cursor
+
+			got, err := decodeCursor(tt.sortBy, encodeCursor(tt.sortBy, withSeats))
+			if err != nil {
+				t.Fatalf("decodeCursor() error = %v", err)
+			}
+			if got != tt.run {
+				t.Errorf("decodeCursor() = %+v, want %+v", got, tt.run)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		sortBy string
+		cursor string
+	}{
+		{name: "not base64", sortBy: SortPriceAsc, cursor: "%%%"},
+		{name: "not json", sortBy: SortPriceAsc, cursor: base64.RawURLEncoding.EncodeToString([]byte("price"))},
+		{name: "padded base64", sortBy: SortPriceAsc, cursor: base64.URLEncoding.EncodeToString([]byte(`{"o":"price_asc"}`))},
+		{name: "cursor of another sort", sortBy: SortPriceDesc, cursor: encodeCursor(SortPriceAsc, run(1, 10, 100, 3, 3))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decodeCursor(tt.sortBy, tt.cursor); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("decodeCursor(%q) error = %v, want %v", tt.cursor, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
+
+func TestCompareRuns(t *testing.T) {
+	tests := []struct {
+		name   string
+		sortBy string
+		left   seatRun
+		right  seatRun
+		want   int // sign only
+	}{
+		{name: "price asc: cheaper first", sortBy: SortPriceAsc, left: run(2, 20, 100, 1, 1), right: run(1, 10, 200, 1, 1), want: -1},
+		{name: "price desc: dearer first", sortBy: SortPriceDesc, left: run(2, 20, 200, 1, 1), right: run(1, 10, 100, 1, 1), want: -1},
+		{name: "best view: front section first", sortBy: SortBestView, left: ranked(run(2, 20, 300, 1, 1), 1, 2), right: ranked(run(1, 10, 100, 1, 1), 2, 1), want: -1},
+		{name: "best view: front row first", sortBy: SortBestView, left: ranked(run(1, 11, 300, 1, 1), 1, 1), right: ranked(run(1, 10, 100, 1, 1), 1, 2), want: -1},
+		{name: "best view: ties broken by price", sortBy: SortBestView, left: ranked(run(2, 20, 100, 1, 1), 1, 1), right: ranked(run(1, 10, 300, 1, 1), 1, 1), want: -1},
+		{name: "length: longer first", sortBy: SortLength, left: run(2, 20, 300, 1, 6), right: run(1, 10, 100, 1, 2), want: -1},
+		{name: "ties broken by price", sortBy: SortLength, left: run(2, 20, 100, 1, 2), right: run(1, 10, 300, 1, 2), want: -1},
+		{name: "ties broken by section", sortBy: SortPriceAsc, left: run(1, 20, 100, 1, 1), right: run(2, 10, 100, 1, 1), want: -1},
+		{name: "ties broken by seat number", sortBy: SortPriceAsc, left: run(1, 10, 100, 5, 1), right: run(1, 10, 100, 2, 1), want: 1},
+		{name: "same run", sortBy: SortBestView, left: run(1, 10, 100, 2, 3), right: run(1, 10, 100, 2, 3), want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareRuns(tt.left, tt.right, tt.sortBy)
+			if sign(got) != tt.want {
+				t.Errorf("compareRuns() = %d, want sign %d", got, tt.want)
+			}
+			if reverse := compareRuns(tt.right, tt.left, tt.sortBy); sign(reverse) != -tt.want {
+				t.Errorf("compareRuns() reversed = %d, want sign %d", reverse, -tt.want)
+			}
+		})
+	}
+}
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestPageRuns(t *testing.T) {
+	sorted := []seatRun{ // price asc
+		run(1, 10, 100, 3, 3),
+		run(1, 11, 100, 1, 4),
+		run(2, 20, 100, 1, 2),
+		run(2, 21, 200, 2, 6),
+		run(1, 10, 300, 5, 1),
+	}
+
+	tests := []struct {
+		name       string
+		runs       []seatRun // testRuns when nil
+		search     TicketSearch
+		want       []seatRun
+		wantCursor bool
+		wantErr    error
+	}{
+		{name: "first page", search: TicketSearch{Page: 1, PageSize: 2}, want: sorted[:2], wantCursor: true},
+		{name: "middle page", search: TicketSearch{Page: 2, PageSize: 2}, want: sorted[2:4], wantCursor: true},
+		{name: "last partial page", search: TicketSearch{Page: 3, PageSize: 2}, want: sorted[4:]},
+		{name: "last full page has no cursor", search: TicketSearch{Page: 1, PageSize: 5}, want: sorted},
+		{name: "page past the end", search: TicketSearch{Page: 4, PageSize: 2}},
+		{name: "page zero", search: TicketSearch{Page: 0, PageSize: 2}},
+		{name: "no runs", runs: []seatRun{}, search: TicketSearch{Page: 1, PageSize: 2}},
+		{
+			name:       "cursor starts after its run",
+			search:     TicketSearch{PageSize: 2, Cursor: encodeCursor(SortPriceAsc, sorted[1])},
+			want:       sorted[2:4],
+			wantCursor: true,
+		},
+		{
+			name:       "cursor of a run taken meanwhile",
+			runs:       append(append([]seatRun{}, sorted[:1]...), sorted[2:]...),
+			search:     TicketSearch{PageSize: 2, Cursor: encodeCursor(SortPriceAsc, sorted[1])},
+			want:       sorted[2:4],
+			wantCursor: true,
+		},
+		{name: "cursor wins over page", search: TicketSearch{Page: 1, PageSize: 2, Cursor: encodeCursor(SortPriceAsc, sorted[3])}, want: sorted[4:]},
+		{name: "cursor of the last run", search: TicketSearch{PageSize: 2, Cursor: encodeCursor(SortPriceAsc, sorted[4])}},
+		{name: "invalid cursor", search: TicketSearch{PageSize: 2, Cursor: "%%%"}, wantErr: ErrInvalidCursor},
+		{
+			name:    "cursor of another sort",
+			search:  TicketSearch{Sort: SortLength, PageSize: 2, Cursor: encodeCursor(SortPriceAsc, sorted[1])},
+			wantErr: ErrInvalidCursor,
+		},
+		{
+			name:       "sorted by length",
+			search:     TicketSearch{Sort: SortLength, Page: 1, PageSize: 3},
+			want:       []seatRun{run(2, 21, 200, 2, 6), run(1, 11, 100, 1, 4), run(1, 10, 100, 3, 3)},
+			wantCursor: true,
+		},
+		{
+			name: "sorted by best view, the rank and not the ID",
+			runs: []seatRun{
+				ranked(run(1, 10, 100, 1, 2), 2, 1),
+				ranked(run(2, 21, 100, 1, 2), 1, 2),
+				ranked(run(2, 20, 100, 1, 2), 1, 1),
+			},
+			search: TicketSearch{Sort: SortBestView, Page: 1, PageSize: 3},
+			want: []seatRun{
+				ranked(run(2, 20, 100, 1, 2), 1, 1),
+				ranked(run(2, 21, 100, 1, 2), 1, 2),
+				ranked(run(1, 10, 100, 1, 2), 2, 1),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runs := tt.runs
+			if runs == nil {
+				runs = testRuns()
+			}
+
+			got, cursor, err := pageRuns(runs, tt.search)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("pageRuns() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(got) != len(tt.want) || (len(got) > 0 && !reflect.DeepEqual(got, tt.want)) {
+				t.Errorf("pageRuns() = %+v, want %+v", got, tt.want)
+			}
+			if (cursor != "") != tt.wantCursor {
+				t.Errorf("pageRuns() cursor = %q, want a cursor: %v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestPageRunsCursorWalk(t *testing.T) {
+	for _, sortBy := range []string{SortPriceAsc, SortPriceDesc, SortBestView, SortLength} {
+		t.Run(sortBy, func(t *testing.T) {
+			all, _, err := pageRuns(testRuns(), TicketSearch{Sort: sortBy, Page: 1, PageSize: 100})
+			if err != nil {
+				t.Fatalf("pageRuns() error = %v", err)
+			}
+
+			// Pages of 2 through the cursor cover every run once, in order
+			var walked []seatRun
+			search := TicketSearch{Sort: sortBy, Page: 1, PageSize: 2}
+			for i := 0; i < len(all); i++ {
+				page, cursor, err := pageRuns(testRuns(), search)
+				if err != nil {
+					t.Fatalf("pageRuns() error = %v", err)
+				}
+				walked = append(walked, page...)
+				if cursor == "" {
+					break
+				}
+				search.Cursor = cursor
+			}
+
+			if !reflect.DeepEqual(walked, all) {
+				t.Errorf("walked %+v, want %+v", walked, all)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"ticket-booking-backend/domain/venue"
+	"ticket-booking-backend/dto"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+var ErrSeatNotOnSale = errors.New("seat is not on sale for this event")
+
+// ReserveSeats queues the reservation of the exact seats picked on the seat map.
+// The seats are located in the database here, the consumer checks and holds them all or none.
+func (s *TicketService) ReserveSeats(ctx *gin.Context, eventID, price int, seatIDs []int) (string, error) {
+	sessionID, exists := ctx.Get("session_id")
+	if !exists {
+		return "", fmt.Errorf("session ID not found in context")
+	}
+
+	seatLocations, err := s.venueService.GetEventSeatLocations(eventID, seatIDs)
+	if err != nil {
+		return "", err
+	}
+	if len(seatLocations) != len(seatIDs) {
+		return "", ErrSeatNotOnSale
+	}
+
+	seats := make([]dto.SeatRef, 0, len(seatLocations))
+	for _, seatLocation := range seatLocations {
+		if seatLocation.Price != price {
+			return "", ErrSeatPriceMismatch
+		}
+		seats = append(seats, dto.SeatRef{
+			SeatID:     seatLocation.SeatID,
+			SectionID:  seatLocation.SectionID,
+			RowID:      seatLocation.RowID,
+			SeatNumber: seatLocation.SeatNumber,
+		})
+	}
+
+	return s.publishReservation(ctx, dto.ReservationMsg{
+		Mode:      dto.ReservationModeSeats,
+		RequestID: uuid.NewString(),
+		EventID:   eventID,
+		Price:     price,
+		Length:    len(seats),
+		Seats:     seats,
+		SessionID: sessionID.(string),
+	})
+}
+
+// reserveSpecificSeats holds the seats of msg if every one of them is available at the price,
+// one hold per run of consecutive seats. The rows and price blocks read are watched.
+func (s *TicketService) reserveSpecificSeats(ctx context.Context, msg dto.ReservationMsg) (reservationResult, error) {
+	// section ID -> row ID -> seat numbers
+	seatsByRow := make(map[int]map[int][]int)
+	var priceBlocksKeys []string
+	for _, seat := range msg.Seats {
+		if _, exists := seatsByRow[seat.SectionID]; !exists {
+			seatsByRow[seat.SectionID] = make(map[int][]int)
+			priceBlocksKeys = append(priceBlocksKeys, fmt.Sprintf("event:%d:section:%d:price_blocks", msg.EventID, seat.SectionID))
+		}
+		seatsByRow[seat.SectionID][seat.RowID] = append(seatsByRow[seat.SectionID][seat.RowID], seat.SeatNumber)
+	}
+
+	var result reservationResult
+
+	err := s.redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+		// A previous attempt that timed out may have applied the request
+		if applied, found, err := getAppliedReservation(ctx, tx, msg.RequestID); err != nil {
+			return err
+		} else if found {
+			result = applied
+			return nil
+		}
+
+		result = reservationResult{Price: msg.Price}      // every seat was checked against the price blocks of this price
+		updatedRows := make(map[string]map[string]string) // rows key -> row ID -> row data
+
+		for _, sectionID := range sortedKeys(seatsByRow) {
+			seatsKey := fmt.Sprintf("event:%d:section:%d:rows", msg.EventID, sectionID)
+			priceBlocksKey := fmt.Sprintf("event:%d:section:%d:price_blocks", msg.EventID, sectionID)
+			updatedRows[seatsKey] = make(map[string]string)
+
+			priceBlocks, err := getPriceBlocks(ctx, tx, msg.EventID, sectionID, msg.Price, msg.Price, s.venueService)
+			if err != nil {
+				return err
+			}
+
+			for _, rowID := range sortedKeys(seatsByRow[sectionID]) {
+				seatNumbers := seatsByRow[sectionID][rowID]
+				sort.Ints(seatNumbers)
+
+				// Caches the row on the first read, it is watched from then on
+				seatStatuses, err := getConsecutiveSeatBlocks(ctx, tx, msg.EventID, sectionID, s.venueService, &venue.SeatPriceBlock{RowID: rowID})
+				if err != nil {
+					return err
+				}
+				if seatStatuses == "" {
+					return ErrRowNotFound
+				}
+
+				seats := []rune(seatStatuses)
+				for _, seatNumber := range seatNumbers {
+					if !inPriceBlocks(priceBlocks, rowID, seatNumber) {
+						return ErrSeatPriceMismatch
+					}
+					if seatNumber > len(seats) || seats[seatNumber-1] != '0' {
+						return ErrSeatUnavailable
+					}
+					seats[seatNumber-1] = '1'
+				}
+
+				rowDataStr, err := tx.HGet(ctx, seatsKey, fmt.Sprintf("%d", rowID)).Result()
+				if err != nil {
+					return fmt.Errorf("failed to get row data: %w", err)
+				}
+				var rowInfo rowData
+				if err := json.Unmarshal([]byte(rowDataStr), &rowInfo); err != nil {
+					return fmt.Errorf("failed to decode row data: %w", err)
+				}
+				rowInfo.Seats = string(seats)
+				updatedRowData, err := json.Marshal(rowInfo)
+				if err != nil {
+					return fmt.Errorf("failed to encode updated row data: %w", err)
+				}
+				updatedRows[seatsKey][fmt.Sprintf("%d", rowID)] = string(updatedRowData)
+
+				priceMaxConsecutive, err := getPriceMaxConsecutive(ctx, tx, priceBlocksKey, rowID, seats)
+				if err != nil {
+					return err
+				}
+				result.Rows = append(result.Rows, rowAvailability{
+					SectionID:           sectionID,
+					RowID:               rowID,
+					PriceMaxConsecutive: priceMaxConsecutive,
+				})
+
+				// One hold per run of consecutive seat numbers
+				for start := 0; start < len(seatNumbers); {
+					end := start + 1
+					for end < len(seatNumbers) && seatNumbers[end] == seatNumbers[end-1]+1 {
+						end++
+					}
+					startSeatNumber, length := seatNumbers[start], end-start
+					result.Holds = append(result.Holds, Hold{
+						ID:              getHoldID(msg.EventID, sectionID, rowID, startSeatNumber, length),
+						EventID:         msg.EventID,
+						SectionID:       sectionID,
+						RowID:           rowID,
+						StartSeatNumber: startSeatNumber,
+						Length:          length,
+					})
+					start = end
+				}
+			}
+		}
+
+		// Every seat is available, hold them all at once
+		_, err := tx.TxPipelined(ctx, func(pipe redislib.Pipeliner) error {
+			for seatsKey, rows := range updatedRows {
+				for rowID, data := range rows {
+					pipe.HSet(ctx, seatsKey, rowID, data)
+				}
+			}
+			for _, hold := range result.Holds {
+				setReservation(ctx, pipe, msg.SessionID, hold.EventID, hold.SectionID, hold.RowID, hold.StartSeatNumber, hold.Length)
+			}
+			return setAppliedReservation(ctx, pipe, msg.RequestID, result)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update seats data: %w", err)
+		}
+		return nil
+	}, priceBlocksKeys...)
+
+	return result, err
+}
+
+// inPriceBlocks tells whether the seat of the row is in one of the price blocks
+func inPriceBlocks(priceBlocks []venue.SeatPriceBlock, rowID, seatNumber int) bool {
+	for _, priceBlock := range priceBlocks {
+		if priceBlock.RowID == rowID && priceBlock.StartSeatNumber <= seatNumber && seatNumber <= priceBlock.EndSeatNumber {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Ints(keys)
+	return keys
+}
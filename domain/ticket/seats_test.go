@@ -0,0 +1,99 @@
+package ticket
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"ticket-booking-backend/dto"
+)
+
+func TestReserveSpecificSeats(t *testing.T) {
+	// setTwoPriceRow: seats 1-4 at 100, 5-8 at 200, seat 2 taken
+	tests := []struct {
+		name        string
+		price       int
+		seatNumbers []int
+		wantErr     error
+		wantHolds   [][2]int // start seat number, length
+		wantSeats   string
+	}{
+		{
+			name: "consecutive seats make one hold", price: 100, seatNumbers: []int{4, 3},
+			wantHolds: [][2]int{{3, 2}}, wantSeats: "01110000",
+		},
+		{
+			name: "one hold per run of consecutive seats", price: 200, seatNumbers: []int{8, 5, 6},
+			wantHolds: [][2]int{{5, 2}, {8, 1}}, wantSeats: "01001101",
+		},
+		{
+			name: "one taken seat holds none", price: 100, seatNumbers: []int{1, 2, 3},
+			wantErr: ErrSeatUnavailable, wantSeats: "01000000",
+		},
+		{
+			name: "one seat at another price holds none", price: 100, seatNumbers: []int{4, 5},
+			wantErr: ErrSeatPriceMismatch, wantSeats: "01000000",
+		},
+		{
+			name: "seat outside the price blocks", price: 200, seatNumbers: []int{9},
+			wantErr: ErrSeatPriceMismatch, wantSeats: "01000000",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			s, redisClient := newTestTicketService(t)
+			setTwoPriceRow(t, redisClient)
+
+			msg := dto.ReservationMsg{
+				Mode:      dto.ReservationModeSeats,
+				RequestID: "request-1",
+				EventID:   1,
+				Price:     tt.price,
+				Length:    len(tt.seatNumbers),
+				SessionID: testSessionID,
+			}
+			for _, seatNumber := range tt.seatNumbers {
+				msg.Seats = append(msg.Seats, dto.SeatRef{SeatID: 300 + seatNumber, SectionID: 2, RowID: 3, SeatNumber: seatNumber})
+			}
+
+			result, err := s.reserveSpecificSeats(ctx, msg)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("reserveSpecificSeats() error = %v, want %v", err, tt.wantErr)
+			}
+			if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"`+tt.wantSeats+`"}`; got != want {
+				t.Errorf("row = %s, want %s", got, want)
+			}
+
+			var gotHolds [][2]int
+			for _, hold := range result.Holds {
+				gotHolds = append(gotHolds, [2]int{hold.StartSeatNumber, hold.Length})
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(gotHolds, tt.wantHolds) {
+				t.Errorf("holds = %v, want %v", gotHolds, tt.wantHolds)
+			}
+
+			// The session holds exactly the returned holds, nothing on failure
+			var wantHoldIDs []string
+			for _, hold := range tt.wantHolds {
+				wantHoldIDs = append(wantHoldIDs, getHoldID(1, 2, 3, hold[0], hold[1]))
+			}
+			gotHoldIDs, err := redisClient.HKeys(ctx, getReservationKey(testSessionID)).Result()
+			if err != nil {
+				t.Fatalf("failed to get the session holds: %v", err)
+			}
+			if len(gotHoldIDs) != len(wantHoldIDs) {
+				t.Fatalf("session holds = %v, want %v", gotHoldIDs, wantHoldIDs)
+			}
+			for _, holdID := range wantHoldIDs {
+				if exists, _ := redisClient.HExists(ctx, getReservationKey(testSessionID), holdID).Result(); !exists {
+					t.Errorf("session holds = %v, want %v", gotHoldIDs, wantHoldIDs)
+				}
+			}
+			if got := redisClient.ZCard(ctx, holdDeadlinesKey).Val(); got != int64(len(wantHoldIDs)) {
+				t.Errorf("hold deadlines = %d, want %d", got, len(wantHoldIDs))
+			}
+		})
+	}
+}
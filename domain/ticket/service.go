@@ -3,172 +3,332 @@ package ticket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"ticket-booking-backend/cmd/api/websocket"
 	"ticket-booking-backend/domain/event"
 	"ticket-booking-backend/domain/venue"
 	"ticket-booking-backend/dto"
 	"ticket-booking-backend/tool/rabbitmq"
+	"ticket-booking-backend/tool/util"
 	"time"
 
 	"database/sql"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	redislib "github.com/redis/go-redis/v9"
 )
 
+var (
+	// ErrHoldNotFound is returned when a hold is missing from the session, usually because it expired
+	ErrHoldNotFound = errors.New("hold not found or expired")
+
+	// Terminal reservation errors, retrying the request cannot fix them
+	ErrRowNotFound    = errors.New("row not found")
+	ErrNotEnoughSeats = errors.New("not enough consecutive seats available")
+	// Specific seats reservations
+	ErrSeatUnavailable   = errors.New("seat is no longer available")
+	ErrSeatPriceMismatch = errors.New("seat is not sold at this price")
+)
+
+// Booking engines, selected with BOOKING_ENGINE
+const (
+	bookingEngineLua = "lua" // atomic server-side script, the default
+	bookingEngineGo  = "go"  // WATCH transaction, kept for comparison benchmarks
+)
+
+var defaultBookingEngine = bookingEngineLua
+
+// messagePublisher queues the reservations, implemented by rabbitmq.RabbitMQ
+type messagePublisher interface {
+	PublishMessage(action string, body []byte) error
+}
+
 type TicketService struct {
-	mq                *rabbitmq.RabbitMQ
+	mq                messagePublisher
 	redisClient       *redislib.Client
 	db                *sql.DB
 	connectionManager *websocket.ConnectionManager
+	bookingEngine     string
+	bookingScript     *redislib.Script
+	releaseScript     *redislib.Script
+	claimScript       *redislib.Script
+	restoreScript     *redislib.Script
+	moveScript        *redislib.Script
+	venueService      *venue.VenueService // fills the caches read by best available reservations
+	seatPicker        SeatPicker
 }
 
-func NewTicketService(redisClient *redislib.Client, rmq *rabbitmq.RabbitMQ, db *sql.DB) *TicketService {
+func NewTicketService(redisClient *redislib.Client, rmq *rabbitmq.RabbitMQ, db *sql.DB,
+	venueService *venue.VenueService, connectionManager *websocket.ConnectionManager) *TicketService {
+	bookingEngine := util.GetEnvOrDefault("BOOKING_ENGINE", defaultBookingEngine)
+	if bookingEngine != bookingEngineLua && bookingEngine != bookingEngineGo {
+		log.Fatalf(`Unknown booking engine : %s, should be either "lua" or "go"`, bookingEngine)
+	}
+
+	script, err := readLuaBookingScript()
+	if err != nil {
+		log.Fatal(err)
+	}
+	bookingScript := redislib.NewScript(script)
+
+	// Load once at startup, reserveSeatsLua reloads it if redis loses the script cache
+	if bookingEngine == bookingEngineLua {
+		if err := bookingScript.Load(context.Background(), redisClient).Err(); err != nil {
+			log.Fatalf("Failed to load booking script: %v", err)
+		}
+	}
+
+	log.Printf("booking engine: %s", bookingEngine)
+
 	return &TicketService{
-		mq:          rmq,
-		redisClient: redisClient,
-		db:          db,
+		mq:                rmq,
+		redisClient:       redisClient,
+		db:                db,
+		connectionManager: connectionManager,
+		bookingEngine:     bookingEngine,
+		bookingScript:     bookingScript,
+		releaseScript:     redislib.NewScript(releaseHoldScript),
+		claimScript:       redislib.NewScript(claimHoldScript),
+		restoreScript:     redislib.NewScript(restoreHoldScript),
+		moveScript:        redislib.NewScript(moveHoldsScript),
+		venueService:      venueService,
+		seatPicker:        newSeatPicker(),
 	}
 }
 
-func (s *TicketService) GetTickets(ctx *gin.Context,
-	eventID, number, lowPrice, highPrice, page, pageSize int,
-	venueService *venue.VenueService, eventService *event.EventService) ([]Ticket, error) {
-	offset := (page - 1) * pageSize
-	var tickets []Ticket
+func (s *TicketService) GetTickets(ctx *gin.Context, search TicketSearch,
+	venueService *venue.VenueService, eventService *event.EventService) (TicketPage, error) {
+	runs, err := s.searchRuns(ctx, search, venueService)
+	if err != nil {
+		return TicketPage{}, err
+	}
 
-	// Start Redis transaction by watching the key for sections in the price range
+	// Keep the longest run per price, the best view on ties
+	bestRuns := make(map[int]seatRun)
+	for _, run := range runs {
+		best, exists := bestRuns[run.Price]
+		if !exists || run.Length > best.Length ||
+			(run.Length == best.Length && compareRuns(run, best, SortBestView) < 0) {
+			bestRuns[run.Price] = run
+		}
+	}
 
-	err := s.redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+	var qualifying []seatRun
+	for _, run := range bestRuns {
+		if run.Length >= search.Number {
+			qualifying = append(qualifying, run)
+		}
+	}
 
-		// Check if section data is present
-		sectionIDs, err := getSectionIDs(ctx.Request.Context(), tx, eventID, lowPrice, highPrice, venueService)
+	page, nextCursor, err := pageRuns(qualifying, search)
+	if err != nil {
+		return TicketPage{}, err
+	}
+
+	tickets := make([]Ticket, 0, len(page))
+	for _, run := range page {
+		sectionName, err := venueService.GetSectionNameByID(run.SectionID)
 		if err != nil {
-			return err
+			return TicketPage{}, err
+		}
+		rowName, err := venueService.GetRowNameByID(run.RowID)
+		if err != nil {
+			return TicketPage{}, err
 		}
 
-		fmt.Printf("sectionIDs:%+v", sectionIDs)
+		tickets = append(tickets, Ticket{
+			EventID:     search.EventID,
+			SectionID:   run.SectionID,
+			SectionName: sectionName,
+			RowID:       run.RowID,
+			RowName:     rowName,
+			Price:       run.Price,
+			Length:      run.Length,
+		})
+	}
 
-		count := 0
-		priceInfoMap := make(map[int]priceInfo) // Initialize the map to store price info
+	return TicketPage{Tickets: tickets, NextCursor: nextCursor, Total: len(qualifying)}, nil
+}
 
-		// Fetch price blocks and seat availability for each section
-		for _, sectionID := range sectionIDs {
+// GetTicketRuns lists every run of at least number available seats in the price range, with the seats it can start at
+func (s *TicketService) GetTicketRuns(ctx *gin.Context, search TicketSearch,
+	venueService *venue.VenueService) (TicketRunPage, error) {
+	runs, err := s.searchRuns(ctx, search, venueService)
+	if err != nil {
+		return TicketRunPage{}, err
+	}
 
-			seatBlocks, err := getPriceBlocks(ctx, tx, eventID, sectionID, lowPrice, highPrice, venueService)
-			if err != nil {
-				return err
-			}
+	var qualifying []seatRun
+	for _, run := range runs {
+		if run.Length >= search.Number {
+			qualifying = append(qualifying, run)
+		}
+	}
 
-			// Calculate max length per price
-			for _, priceBlock := range seatBlocks {
+	page, nextCursor, err := pageRuns(qualifying, search)
+	if err != nil {
+		return TicketRunPage{}, err
+	}
 
-				// Get the row condition of this priceBlock
-				seatStatuses, err := getConsecutiveSeatBlocks(ctx, tx, eventID, sectionID, venueService, &priceBlock)
-				if err != nil {
-					return err
-				}
+	ticketRuns := make([]TicketRun, 0, len(page))
+	for _, run := range page {
+		sectionName, err := venueService.GetSectionNameByID(run.SectionID)
+		if err != nil {
+			return TicketRunPage{}, err
+		}
+		rowName, err := venueService.GetRowNameByID(run.RowID)
+		if err != nil {
+			return TicketRunPage{}, err
+		}
 
-				seatStatusesPiece := seatStatuses[priceBlock.StartSeatNumber-1 : priceBlock.EndSeatNumber]
-
-				maxLen := 0
-				curLen := 0
-				for _, status := range seatStatusesPiece {
-					if status == '0' {
-						curLen += 1
-					} else {
-						if curLen > maxLen {
-							maxLen = curLen
-						}
-						curLen = 0
-					}
-				}
+		var startSeatNumbers []int
+		for start := run.StartSeatNumber; start+search.Number <= run.StartSeatNumber+run.Length; start++ {
+			startSeatNumbers = append(startSeatNumbers, start)
+		}
 
-				// Final update
-				if curLen > maxLen {
-					maxLen = curLen
-				}
+		ticketRuns = append(ticketRuns, TicketRun{
+			EventID:          search.EventID,
+			SectionID:        run.SectionID,
+			SectionName:      sectionName,
+			RowID:            run.RowID,
+			RowName:          rowName,
+			Price:            run.Price,
+			Length:           run.Length,
+			StartSeatNumbers: startSeatNumbers,
+		})
+	}
 
-				// Update the priceIntoMap
-				if maxLen > priceInfoMap[priceBlock.Price].Length {
-					priceInfoMap[priceBlock.Price] = priceInfo{
-						SectionID: sectionID,
-						RowID:     priceBlock.RowID,
-						Length:    maxLen,
-					}
-				}
-			}
+	return TicketRunPage{Tickets: ticketRuns, NextCursor: nextCursor, Total: len(qualifying)}, nil
+}
+
+// searchRuns reads the available runs of the event in the price range, caching the venue data on the first read
+func (s *TicketService) searchRuns(ctx *gin.Context, search TicketSearch, venueService *venue.VenueService) ([]seatRun, error) {
+	var runs []seatRun
+
+	// Start Redis transaction by watching the key for sections in the price range
+	err := s.redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+		// Check if section data is present
+		sectionIDs, err := getSectionIDs(ctx.Request.Context(), tx, search.EventID, search.LowPrice, search.HighPrice, venueService)
+		if err != nil {
+			return err
+		}
+
+		runs, err = getAvailableRuns(ctx, tx, search.EventID, sectionIDs, search.LowPrice, search.HighPrice, venueService)
+		return err
+	})
+	return runs, err
+}
+
+// getAvailableRuns returns the runs of consecutive available seats of each price block in the price range
+func getAvailableRuns(ctx context.Context, tx *redislib.Tx,
+	eventID int, sectionIDs []int, lowPrice, highPrice int,
+	venueService *venue.VenueService) ([]seatRun, error) {
+	var runs []seatRun
+
+	viewRanks, err := getViewRanks(ctx, tx, eventID, venueService)
+	if err != nil {
+		return nil, err
+	}
+
+	// Fetch price blocks and seat availability for each section
+	for _, sectionID := range sectionIDs {
+		seatBlocks, err := getPriceBlocks(ctx, tx, eventID, sectionID, lowPrice, highPrice, venueService)
+		if err != nil {
+			return nil, err
 		}
 
-		fmt.Printf("priceInfoMap:%+v", priceInfoMap)
+		for _, priceBlock := range seatBlocks {
+			// Get the row condition of this priceBlock
+			seatStatuses, err := getConsecutiveSeatBlocks(ctx, tx, eventID, sectionID, venueService, &priceBlock)
+			if err != nil {
+				return nil, err
+			}
 
-		for price, info := range priceInfoMap {
-			if info.Length >= number {
-				if count >= offset && len(tickets) < pageSize {
-					sectionName, err := venueService.GetSectionNameByID(info.SectionID)
-					if err != nil {
-						return err
-					}
-					rowName, err := venueService.GetRowNameByID(info.RowID)
-					if err != nil {
-						return err
-					}
+			seatStatusesPiece := seatStatuses[priceBlock.StartSeatNumber-1 : priceBlock.EndSeatNumber]
 
-					ticket := Ticket{
-						EventID:     eventID,
-						SectionID:   info.SectionID,
-						SectionName: sectionName,
-						RowID:       info.RowID,
-						RowName:     rowName,
-						Price:       price,
-						Length:      info.Length,
-					}
-					tickets = append(tickets, ticket)
+			curLen := 0
+			for i, status := range seatStatusesPiece + "1" { // the sentinel ends the last run
+				if status == '0' {
+					curLen += 1
+					continue
 				}
-				count++
-				if len(tickets) == pageSize {
-					break
+				if curLen > 0 {
+					runs = append(runs, seatRun{
+						SectionID:       sectionID,
+						RowID:           priceBlock.RowID,
+						SectionRank:     viewRanks.Sections[sectionID],
+						RowRank:         viewRanks.Rows[priceBlock.RowID],
+						Price:           priceBlock.Price,
+						StartSeatNumber: priceBlock.StartSeatNumber + i - curLen,
+						Length:          curLen,
+						Seats:           seatStatuses,
+					})
 				}
+				curLen = 0
 			}
 		}
-		return nil
-	})
-
-	if err != nil {
-		return []Ticket{}, err
 	}
 
-	return tickets, nil
+	return runs, nil
 }
 
-func (s *TicketService) ReserveTicket(ctx *gin.Context, eventID, sectionID, rowID, price, length int) error {
+// ReserveTicket queues the reservation and returns the request ID used to correlate its result.
+// Without section and row, the best available seats at the price are picked in any section.
+func (s *TicketService) ReserveTicket(ctx *gin.Context, eventID, sectionID, rowID, price, length int) (string, error) {
 	sessionID, exists := ctx.Get("session_id")
 	if !exists {
-		return fmt.Errorf("session ID not found in context")
+		return "", fmt.Errorf("session ID not found in context")
+	}
+
+	mode := dto.ReservationModeRow
+	if sectionID == 0 && rowID == 0 {
+		mode = dto.ReservationModeBestAvailable
 	}
 
-	msg := dto.ReservationMsg{
+	return s.publishReservation(ctx, dto.ReservationMsg{
+		Mode:      mode,
+		RequestID: uuid.NewString(),
 		EventID:   eventID,
 		SectionID: sectionID,
 		RowID:     rowID,
 		Price:     price,
 		Length:    length,
 		SessionID: sessionID.(string),
-	}
+	})
+}
 
+// publishReservation records the pending request and queues the reservation
+func (s *TicketService) publishReservation(ctx *gin.Context, msg dto.ReservationMsg) (string, error) {
 	msgBytes, err := json.Marshal(msg)
 	if err != nil {
-		return fmt.Errorf("failed to serialize message: %w", err)
+		return "", fmt.Errorf("failed to serialize message: %w", err)
+	}
+
+	// Record the request before publishing, the consumer may answer right away
+	err = s.setReservationRequest(ctx.Request.Context(), ReservationRequest{
+		RequestID: msg.RequestID,
+		SessionID: msg.SessionID,
+		EventID:   msg.EventID,
+		Status:    RequestStatusPending,
+	})
+	if err != nil {
+		return "", err
 	}
 
 	err = s.mq.PublishMessage("book", msgBytes)
 	if err != nil {
-		return fmt.Errorf("failed to push to message queue: %w", err)
+		// Nothing will answer the request, a client polling it must not wait for the TTL
+		if updateErr := s.updateReservationRequest(msg, RequestStatusFailed, dto.ReasonInternalError, "failed to queue the reservation"); updateErr != nil {
+			log.Printf("failed to update reservation request %s: %v", msg.RequestID, updateErr)
+		}
+		return "", fmt.Errorf("failed to push to message queue: %w", err)
 	}
 
-	return nil
+	return msg.RequestID, nil
 }
 
 func (s *TicketService) HandleBookingMessage(data []byte) error {
@@ -177,26 +337,86 @@ func (s *TicketService) HandleBookingMessage(data []byte) error {
 		return fmt.Errorf("failed to unmarshal msg, error: %w", err)
 	}
 
+	result, err := s.reserveSeatsWithRetry(msg)
+	if err != nil {
+		log.Printf("reservation failed for session %s: %v", msg.SessionID, err)
+
+		reason, message := getFailureDetails(err)
+		if updateErr := s.updateReservationRequest(msg, RequestStatusFailed, reason, message); updateErr != nil {
+			log.Printf("failed to update reservation request %s: %v", msg.RequestID, updateErr)
+		}
+
+		// The message is not requeued, so this is the last chance to tell the user
+		if notifyErr := s.notifyReservationFailure(msg, err); notifyErr != nil {
+			log.Printf("failed to notify WebSocket client: %v", notifyErr)
+		}
+
+		// Rejections are a valid outcome, only unexpected failures are nacked
+		if isRejectedReservationErr(err) {
+			return nil
+		}
+		return err
+	}
+
+	var holdIDs []string
+	for _, hold := range result.Holds {
+		log.Printf("reserved %d seats from seat number %d in row %d", hold.Length, hold.StartSeatNumber, hold.RowID)
+		holdIDs = append(holdIDs, hold.ID)
+	}
+
+	if err := s.updateReservationRequest(msg, RequestStatusSucceeded, "", "", holdIDs...); err != nil {
+		log.Printf("failed to update reservation request %s: %v", msg.RequestID, err)
+	}
+
+	// Notify WebSocket client and broadcast the reservation
+	for _, hold := range result.Holds {
+		if err := s.NotifyReservation(msg, hold, result.Price); err != nil {
+			log.Printf("failed to notify WebSocket client: %v", err)
+		}
+	}
+
+	for _, row := range result.Rows {
+		if err := s.broadcastReservation(msg.EventID, row.SectionID, row.RowID, row.PriceMaxConsecutive); err != nil {
+			log.Printf("failed to broadcast reservation: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// isRejectedReservationErr tells the reservations refused because of the seats, not because of a failure
+func isRejectedReservationErr(err error) bool {
+	return errors.Is(err, ErrRowNotFound) || errors.Is(err, ErrNotEnoughSeats) ||
+		errors.Is(err, ErrSeatUnavailable) || errors.Is(err, ErrSeatPriceMismatch)
+}
+
+// reserveSeats is the Go implementation of the booking script, an optimistic WATCH transaction
+func (s *TicketService) reserveSeats(ctx context.Context, msg dto.ReservationMsg) (reservationResult, error) {
 	// Redis keys
 	seatsKey := fmt.Sprintf("event:%d:section:%d:rows", msg.EventID, msg.SectionID)
 	priceBlocksKey := fmt.Sprintf("event:%d:section:%d:price_blocks", msg.EventID, msg.SectionID)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	var result reservationResult
 
 	err := s.redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+		// A previous attempt that timed out may have applied the request
+		if applied, found, err := getAppliedReservation(ctx, tx, msg.RequestID); err != nil {
+			return err
+		} else if found {
+			result = applied
+			return nil
+		}
+
 		// Step 1: Fetch and decode row data
-		rowData, err := tx.HGet(ctx, seatsKey, fmt.Sprintf("%d", msg.RowID)).Result()
+		rowDataStr, err := tx.HGet(ctx, seatsKey, fmt.Sprintf("%d", msg.RowID)).Result()
 		if err == redislib.Nil {
-			return fmt.Errorf("row not found")
+			return ErrRowNotFound
 		} else if err != nil {
 			return fmt.Errorf("failed to get row data: %w", err)
 		}
 
-		var rowInfo struct {
-			Seats string `json:"seats"`
-		}
-		if err := json.Unmarshal([]byte(rowData), &rowInfo); err != nil {
+		var rowInfo rowData
+		if err := json.Unmarshal([]byte(rowDataStr), &rowInfo); err != nil {
 			return fmt.Errorf("failed to decode row data: %w", err)
 		}
 
@@ -206,9 +426,27 @@ func (s *TicketService) HandleBookingMessage(data []byte) error {
 		reservedSeats := make(map[int]bool)
 		startSeatNumber := 0
 
+		// Only the seats of the row sold at the requested price can be reserved
+		priceBlocks, err := getSeatPriceBlocks(ctx, tx, msg.EventID, msg.SectionID, msg.Price, msg.Price)
+		if err != nil {
+			return err
+		}
+		atPrice := make(map[int]bool) // 0-based seat positions
+		for _, priceBlock := range priceBlocks {
+			if priceBlock.RowID != msg.RowID {
+				continue
+			}
+			for seatNumber := priceBlock.StartSeatNumber; seatNumber <= priceBlock.EndSeatNumber; seatNumber++ {
+				atPrice[seatNumber-1] = true
+			}
+		}
+		if len(atPrice) == 0 {
+			return ErrSeatPriceMismatch
+		}
+
 		// Find and reserve seats
 		for i := 0; i < seatCount; i++ {
-			if seats[i] == '0' { // Available
+			if seats[i] == '0' && atPrice[i] { // Available at the price
 				consecutiveCount++
 			} else {
 				consecutiveCount = 0
@@ -228,7 +466,7 @@ func (s *TicketService) HandleBookingMessage(data []byte) error {
 		}
 
 		if len(reservedSeats) == 0 {
-			return fmt.Errorf("not enough consecutive seats available")
+			return ErrNotEnoughSeats
 		}
 
 		// calculate the new row data
@@ -239,98 +477,176 @@ func (s *TicketService) HandleBookingMessage(data []byte) error {
 		}
 
 		// Get max consecutive lengths for price blocks
-		priceBlocks, err := tx.ZRangeWithScores(ctx, priceBlocksKey, 0, -1).Result()
+		priceMaxConsecutive, err := getPriceMaxConsecutive(ctx, tx, priceBlocksKey, msg.RowID, seats)
 		if err != nil {
-			return fmt.Errorf("failed to get price blocks: %w", err)
+			return err
 		}
 
-		priceMaxConsecutive := map[int]int{}
-		for _, block := range priceBlocks {
-			member := block.Member.(string)
-			price := int(block.Score)
+		result = newRowReservationResult(msg.EventID, msg.SectionID, msg.RowID, startSeatNumber, msg.Length, msg.Price, priceMaxConsecutive)
 
-			// Extract start and end seat numbers from the block key
+		// Update data to redis : do this in the end to handle checks and preparations before.
+		// Writes go through MULTI/EXEC so a concurrent change of the watched keys aborts them.
+		_, err = tx.TxPipelined(ctx, func(pipe redislib.Pipeliner) error {
+			pipe.HSet(ctx, seatsKey, fmt.Sprintf("%d", msg.RowID), string(updatedRowData))
 
-			var rowID, startSeatID, startSeatNum, endSeatID, endSeatNum int
-			_, err := fmt.Sscanf(member, "%d:%d:%d:%d:%d", &rowID, &startSeatID, &startSeatNum, &endSeatID, &endSeatNum)
-			if err != nil {
-				return fmt.Errorf("failed to parse price block: %w", err)
-			}
+			// Add reservation in redis
+			setReservation(ctx, pipe, msg.SessionID, msg.EventID, msg.SectionID, msg.RowID, startSeatNumber, msg.Length)
+			return setAppliedReservation(ctx, pipe, msg.RequestID, result)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update seats data: %w", err)
+		}
 
-			if rowID != msg.RowID {
-				continue
-			}
+		return nil
+	}, seatsKey, priceBlocksKey)
 
-			// Calculate max consecutive available seats in this certain price block
-			maxLength, currentLength := 0, 0
-			for i := startSeatNum; i <= endSeatNum; i++ {
-				if seats[i-1] == '0' {
-					currentLength++
-				} else {
-					if currentLength > maxLength {
-						maxLength = currentLength
-					}
-					currentLength = 0
-				}
-			}
-			if currentLength > maxLength {
-				maxLength = currentLength
-			}
-			priceMaxConsecutive[price] = maxLength
+	return result, err
+}
+
+// reserveBestAvailable lets the seat picker choose among the runs at the price in every section,
+// then holds the seats. The rows read are watched, a concurrent reservation aborts the transaction.
+func (s *TicketService) reserveBestAvailable(ctx context.Context, msg dto.ReservationMsg) (reservationResult, error) {
+	var result reservationResult
+
+	err := s.redisClient.Watch(ctx, func(tx *redislib.Tx) error {
+		// A previous attempt that timed out may have applied the request
+		if applied, found, err := getAppliedReservation(ctx, tx, msg.RequestID); err != nil {
+			return err
+		} else if found {
+			result = applied
+			return nil
 		}
 
-		// Update data to redis : do this in the end to handle checks and preparations before
-		_, err = tx.HSet(ctx, seatsKey, fmt.Sprintf("%d", msg.RowID), string(updatedRowData)).Result()
+		sectionIDs, err := getSectionIDs(ctx, tx, msg.EventID, msg.Price, msg.Price, s.venueService)
 		if err != nil {
-			return fmt.Errorf("failed to update seats data: %w", err)
+			return err
 		}
 
-		// Add reservation in redis
-		err = setReservation(ctx, tx, msg.SessionID, msg.EventID, msg.SectionID, msg.RowID, startSeatNumber, msg.Length)
+		runs, err := getAvailableRuns(ctx, tx, msg.EventID, sectionIDs, msg.Price, msg.Price, s.venueService)
 		if err != nil {
 			return err
 		}
 
-		// Notify WebSocket client and broadcast the reservation
-		if err := s.NotifyReservation(msg); err != nil {
-			log.Printf("failed to notify WebSocket client: %v", err)
+		run, startSeatNumber, found := s.seatPicker.Pick(runs, msg.Length)
+		if !found {
+			return ErrNotEnoughSeats
 		}
 
-		if err := s.broadcastReservation(msg, priceMaxConsecutive); err != nil {
-			log.Printf("failed to broadcast reservation: %v", err)
+		seatsKey := fmt.Sprintf("event:%d:section:%d:rows", msg.EventID, run.SectionID)
+		priceBlocksKey := fmt.Sprintf("event:%d:section:%d:price_blocks", msg.EventID, run.SectionID)
+
+		rowDataStr, err := tx.HGet(ctx, seatsKey, fmt.Sprintf("%d", run.RowID)).Result()
+		if err == redislib.Nil {
+			return ErrRowNotFound
+		} else if err != nil {
+			return fmt.Errorf("failed to get row data: %w", err)
 		}
 
-		return err
-	}, seatsKey, priceBlocksKey)
+		var rowInfo rowData
+		if err := json.Unmarshal([]byte(rowDataStr), &rowInfo); err != nil {
+			return fmt.Errorf("failed to decode row data: %w", err)
+		}
+
+		seats := []rune(rowInfo.Seats)
+		for i := startSeatNumber - 1; i < startSeatNumber-1+msg.Length; i++ {
+			seats[i] = '1'
+		}
+		rowInfo.Seats = string(seats)
+		updatedRowData, err := json.Marshal(rowInfo)
+		if err != nil {
+			return fmt.Errorf("failed to encode updated row data: %w", err)
+		}
+
+		priceMaxConsecutive, err := getPriceMaxConsecutive(ctx, tx, priceBlocksKey, run.RowID, seats)
+		if err != nil {
+			return err
+		}
+
+		result = newRowReservationResult(msg.EventID, run.SectionID, run.RowID, startSeatNumber, msg.Length, run.Price, priceMaxConsecutive)
 
-	return err
+		_, err = tx.TxPipelined(ctx, func(pipe redislib.Pipeliner) error {
+			pipe.HSet(ctx, seatsKey, fmt.Sprintf("%d", run.RowID), string(updatedRowData))
+			setReservation(ctx, pipe, msg.SessionID, msg.EventID, run.SectionID, run.RowID, startSeatNumber, msg.Length)
+			return setAppliedReservation(ctx, pipe, msg.RequestID, result)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to update seats data: %w", err)
+		}
+		return nil
+	})
+
+	return result, err
+}
+
+// NotifyReservation sends a hold of the reservation to the session, a reservation of several holds sends one message per hold.
+// price is the price charged per seat.
+func (s *TicketService) NotifyReservation(msg dto.ReservationMsg, hold Hold, price int) error {
+	notificationMsg := dto.NotificationMsg{
+		RequestID:       msg.RequestID,
+		HoldID:          hold.ID,
+		StartSeatNumber: hold.StartSeatNumber,
+		EventID:         hold.EventID,
+		SectionID:       hold.SectionID,
+		RowID:           hold.RowID,
+		Price:           price,
+		Length:          hold.Length,
+		SessionID:       msg.SessionID,
+	}
+
+	data, err := json.Marshal(notificationMsg)
+	if err != nil {
+		return fmt.Errorf("error marshaling reservation message: %w", err)
+	}
+
+	return s.connectionManager.NotifyReservation(data)
 }
 
-func (s *TicketService) NotifyReservation(msg dto.ReservationMsg) error {
-	reservationMsg := dto.ReservationMsg{
+func (s *TicketService) notifyReservationFailure(msg dto.ReservationMsg, reservationErr error) error {
+	reason, message := getFailureDetails(reservationErr)
+
+	failureMsg := dto.FailureMsg{
+		RequestID: msg.RequestID,
 		EventID:   msg.EventID,
 		SectionID: msg.SectionID,
 		RowID:     msg.RowID,
-		Price:     msg.Price,
-		Length:    msg.Length,
+		Reason:    reason,
+		Message:   message,
 		SessionID: msg.SessionID,
 	}
 
-	data, err := json.Marshal(reservationMsg)
+	data, err := json.Marshal(failureMsg)
 	if err != nil {
-		return fmt.Errorf("error marshaling reservation message: %w", err)
+		return fmt.Errorf("error marshaling failure message: %w", err)
 	}
 
-	return s.connectionManager.NotifyReservation(data)
+	return s.connectionManager.NotifySession(msg.SessionID, dto.TypeReservationFailed, data)
+}
+
+// getFailureDetails returns the reason code and the message shown to the client
+func getFailureDetails(err error) (string, string) {
+	switch {
+	case errors.Is(err, ErrRowNotFound):
+		return dto.ReasonRowNotFound, err.Error()
+	case errors.Is(err, ErrNotEnoughSeats):
+		return dto.ReasonNotEnoughSeats, err.Error()
+	case errors.Is(err, ErrSeatUnavailable):
+		return dto.ReasonSeatUnavailable, err.Error()
+	case errors.Is(err, ErrSeatPriceMismatch):
+		return dto.ReasonPriceMismatch, err.Error()
+	case isRetryableBookingErr(err):
+		return dto.ReasonBusy, "seats are in high demand, please try again"
+	default:
+		return dto.ReasonInternalError, "failed to process reservation" // do not leak internals to the client
+	}
 }
 
-func (s *TicketService) broadcastReservation(msg dto.ReservationMsg, priceMaxConsecutive map[int]int) error {
+func (s *TicketService) broadcastReservation(eventID, sectionID, rowID int, priceMaxConsecutive map[int]int) error {
 	var broadcastMsgs dto.BroadcastMsgs
 	for price, length := range priceMaxConsecutive {
 		broadcastMsg := dto.BroadcastMsg{
-			EventID:   msg.EventID,
-			SectionID: msg.SectionID,
-			RowID:     msg.RowID,
+			EventID:   eventID,
+			SectionID: sectionID,
+			RowID:     rowID,
 			Price:     price,
 			MaxLength: length,
 		}
@@ -344,3 +660,144 @@ func (s *TicketService) broadcastReservation(msg dto.ReservationMsg, priceMaxCon
 
 	return s.connectionManager.BroadcastReservation(data)
 }
+
+func (s *TicketService) GetHold(ctx context.Context, sessionID, holdID string) (Hold, error) {
+	hold, err := ParseHoldID(holdID)
+	if err != nil {
+		return Hold{}, err
+	}
+
+	exists, err := s.redisClient.HExists(ctx, getReservationKey(sessionID), holdID).Result()
+	if err != nil {
+		return Hold{}, fmt.Errorf("failed to get hold: %w", err)
+	}
+	if !exists {
+		return Hold{}, ErrHoldNotFound
+	}
+
+	return hold, nil
+}
+
+// RemoveHold drops the hold from the session without releasing its seats,
+// used once the seats are booked for good.
+func (s *TicketService) RemoveHold(ctx context.Context, sessionID, holdID string) error {
+	_, err := s.redisClient.TxPipelined(ctx, func(pipe redislib.Pipeliner) error {
+		pipe.HDel(ctx, getReservationKey(sessionID), holdID)
+		pipe.ZRem(ctx, holdDeadlinesKey, getHoldDeadlineMember(sessionID, holdID))
+		pipe.Del(ctx, getHoldClaimKey(sessionID, holdID))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to remove hold: %w", err)
+	}
+	return nil
+}
+
+// GetHolds returns the holds of a session for an event, ordered by hold ID.
+// The session hash expiry is only ever extended, it can outlive some of its holds:
+// a hold past its deadline is returned until the reaper releases it, ClaimHold refuses it.
+func (s *TicketService) GetHolds(ctx context.Context, sessionID string, eventID int) ([]Hold, error) {
+	sessionHolds, err := s.getSessionHolds(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	holds := []Hold{}
+	for _, hold := range sessionHolds {
+		if hold.EventID == eventID {
+			holds = append(holds, hold)
+		}
+	}
+
+	return holds, nil
+}
+
+func (s *TicketService) getSessionHolds(ctx context.Context, sessionID string) ([]Hold, error) {
+	fields, err := s.redisClient.HGetAll(ctx, getReservationKey(sessionID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get holds: %w", err)
+	}
+
+	holds := []Hold{}
+	for holdID := range fields {
+		hold, err := ParseHoldID(holdID)
+		if err != nil {
+			log.Printf("skipping malformed hold: %v", err)
+			continue
+		}
+		holds = append(holds, hold)
+	}
+
+	sort.Slice(holds, func(i, j int) bool { return holds[i].ID < holds[j].ID })
+
+	return holds, nil
+}
+
+// ListHolds returns every hold of the session with display names and the time left before it expires
+func (s *TicketService) ListHolds(ctx context.Context, sessionID string,
+	venueService *venue.VenueService, eventService *event.EventService) ([]HoldInfo, error) {
+	holds, err := s.getSessionHolds(ctx, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if len(holds) == 0 {
+		return []HoldInfo{}, nil
+	}
+
+	// Deadlines of each hold, the hash TTL is the fallback for holds without one
+	deadlineCmds := make([]*redislib.FloatCmd, len(holds))
+	var ttlCmd *redislib.DurationCmd
+	_, err = s.redisClient.Pipelined(ctx, func(pipe redislib.Pipeliner) error {
+		for i, hold := range holds {
+			deadlineCmds[i] = pipe.ZScore(ctx, holdDeadlinesKey, getHoldDeadlineMember(sessionID, hold.ID))
+		}
+		ttlCmd = pipe.TTL(ctx, getReservationKey(sessionID))
+		return nil
+	})
+	if err != nil && err != redislib.Nil {
+		return nil, fmt.Errorf("failed to get hold deadlines: %w", err)
+	}
+
+	now := time.Now()
+	holdInfos := make([]HoldInfo, 0, len(holds))
+	for i, hold := range holds {
+		expiresAt := now.Add(ttlCmd.Val())
+		if deadline, err := deadlineCmds[i].Result(); err == nil {
+			expiresAt = time.Unix(int64(deadline), 0)
+		}
+		expiresIn := int(expiresAt.Sub(now).Seconds())
+		if expiresIn <= 0 { // waiting for the reaper
+			continue
+		}
+
+		eventName, err := eventService.GetNameByID(hold.EventID)
+		if err != nil {
+			return nil, err
+		}
+		sectionName, err := venueService.GetSectionNameByID(hold.SectionID)
+		if err != nil {
+			return nil, err
+		}
+		rowName, err := venueService.GetRowNameByID(hold.RowID)
+		if err != nil {
+			return nil, err
+		}
+
+		seatNumbers := make([]int, 0, hold.Length)
+		for seatNumber := hold.StartSeatNumber; seatNumber < hold.StartSeatNumber+hold.Length; seatNumber++ {
+			seatNumbers = append(seatNumbers, seatNumber)
+		}
+
+		holdInfos = append(holdInfos, HoldInfo{
+			Hold:        hold,
+			EventName:   eventName,
+			SectionName: sectionName,
+			RowName:     rowName,
+			SeatNumbers: seatNumbers,
+			ExpiresAt:   expiresAt,
+			ExpiresIn:   expiresIn,
+		})
+	}
+
+	return holdInfos, nil
+}
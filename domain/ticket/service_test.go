@@ -0,0 +1,169 @@
+package ticket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"ticket-booking-backend/dto"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	redislib "github.com/redis/go-redis/v9"
+)
+
+// setTwoPriceRow makes row 3 eight seats long, seats 1-4 sold at 100 and 5-8 at 200, seat 2 taken
+func setTwoPriceRow(t *testing.T, redisClient *redislib.Client) {
+	t.Helper()
+
+	ctx := context.Background()
+	priceBlocksKey := "event:1:section:2:price_blocks"
+	redisClient.HSet(ctx, testSeatsKey, "3", `{"row_name":"A","seats":"01000000"}`)
+	redisClient.Del(ctx, priceBlocksKey)
+	redisClient.ZAdd(ctx, priceBlocksKey,
+		redislib.Z{Score: 100, Member: "3:301:1:304:4"},
+		redislib.Z{Score: 200, Member: "3:305:5:308:8"},
+	)
+}
+
+func TestReserveSeatsAtPrice(t *testing.T) {
+	tests := []struct {
+		name      string
+		price     int
+		length    int
+		wantErr   error
+		wantStart int
+		wantSeats string
+		// max consecutive lengths broadcast per price
+		wantAvailability map[int]int
+	}{
+		{
+			name: "cheaper tier", price: 100, length: 2, wantStart: 3, wantSeats: "01110000",
+			wantAvailability: map[int]int{100: 1, 200: 4},
+		},
+		{
+			name: "cheaper tier never spills into the dearer block", price: 100, length: 3,
+			wantErr: ErrNotEnoughSeats, wantSeats: "01000000",
+		},
+		{
+			name: "dearer tier", price: 200, length: 3, wantStart: 5, wantSeats: "01001110",
+			wantAvailability: map[int]int{100: 2, 200: 1},
+		},
+		{
+			name: "price not sold in the row", price: 150, length: 1,
+			wantErr: ErrSeatPriceMismatch, wantSeats: "01000000",
+		},
+	}
+
+	for _, engine := range []string{bookingEngineLua, bookingEngineGo} {
+		for _, tt := range tests {
+			t.Run(engine+": "+tt.name, func(t *testing.T) {
+				s, redisClient := newTestTicketService(t)
+				s.bookingEngine = engine
+				setTwoPriceRow(t, redisClient)
+
+				notifications := redisClient.Subscribe(context.Background(), "ws:notify")
+				defer notifications.Close()
+				if _, err := notifications.Receive(context.Background()); err != nil {
+					t.Fatalf("failed to subscribe: %v", err)
+				}
+
+				msg := dto.ReservationMsg{
+					RequestID: "request-1",
+					EventID:   1,
+					SectionID: 2,
+					RowID:     3,
+					Price:     tt.price,
+					Length:    tt.length,
+					SessionID: testSessionID,
+				}
+
+				result, err := s.reserveSeatsOnce(msg)
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("reserveSeatsOnce() error = %v, want %v", err, tt.wantErr)
+				}
+				if got, want := getTestSeats(t, redisClient), `{"row_name":"A","seats":"`+tt.wantSeats+`"}`; got != want {
+					t.Errorf("row = %s, want %s", got, want)
+				}
+				if tt.wantErr != nil {
+					return
+				}
+
+				if len(result.Holds) != 1 || result.Holds[0].StartSeatNumber != tt.wantStart || result.Holds[0].Length != tt.length {
+					t.Errorf("holds = %+v, want %d seats from seat %d", result.Holds, tt.length, tt.wantStart)
+				}
+				if result.Price != tt.price {
+					t.Errorf("price = %d, want %d", result.Price, tt.price)
+				}
+				if len(result.Rows) != 1 || !equalAvailability(result.Rows[0].PriceMaxConsecutive, tt.wantAvailability) {
+					t.Errorf("availability = %+v, want %v", result.Rows, tt.wantAvailability)
+				}
+
+				// The session is told the price it pays per seat
+				if err := s.NotifyReservation(msg, result.Holds[0], result.Price); err != nil {
+					t.Fatalf("NotifyReservation() error = %v", err)
+				}
+				select {
+				case published := <-notifications.Channel():
+					var sessionMsg struct {
+						Data dto.Envelope `json:"data"`
+					}
+					var notification dto.NotificationMsg
+					if err := json.Unmarshal([]byte(published.Payload), &sessionMsg); err != nil {
+						t.Fatalf("failed to decode notification: %v", err)
+					}
+					if err := json.Unmarshal(sessionMsg.Data.Payload, &notification); err != nil {
+						t.Fatalf("failed to decode notification payload: %v", err)
+					}
+					if notification.Price != tt.price || notification.StartSeatNumber != tt.wantStart {
+						t.Errorf("notification = %+v, want price %d from seat %d", notification, tt.price, tt.wantStart)
+					}
+				case <-time.After(time.Second):
+					t.Fatal("no notification")
+				}
+			})
+		}
+	}
+}
+
+func equalAvailability(got, want map[int]int) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for price, length := range want {
+		if got[price] != length {
+			return false
+		}
+	}
+	return true
+}
+
+type failingPublisher struct{}
+
+func (failingPublisher) PublishMessage(action string, body []byte) error {
+	return errors.New("channel closed")
+}
+
+func TestPublishReservationFailure(t *testing.T) {
+	s, _ := newTestTicketService(t)
+	s.mq = failingPublisher{}
+
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx.Request = httptest.NewRequest(http.MethodPost, "/events/1/tickets/reserve", nil)
+
+	msg := dto.ReservationMsg{RequestID: "request-1", EventID: 1, SectionID: 2, RowID: 3, Price: 100, Length: 2, SessionID: testSessionID}
+	if _, err := s.publishReservation(ctx, msg); err == nil {
+		t.Fatal("publishReservation() error = nil, want the publish error")
+	}
+
+	// The request is not left pending until it expires
+	request, err := s.GetReservationRequest(context.Background(), testSessionID, msg.RequestID)
+	if err != nil {
+		t.Fatalf("GetReservationRequest() error = %v", err)
+	}
+	if request.Status != RequestStatusFailed || request.Reason != dto.ReasonInternalError {
+		t.Errorf("request = %s %s, want %s %s", request.Status, request.Reason, RequestStatusFailed, dto.ReasonInternalError)
+	}
+}
@@ -2,6 +2,7 @@ package user
 
 import (
 	"fmt"
+	"time"
 
 	"database/sql"
 )
@@ -25,3 +26,46 @@ func (repo *UserRepository) Create(user *User) error {
 
 	return nil
 }
+
+func (repo *UserRepository) GetIDBySessionToken(sessionToken string) (int, error) {
+	query := "SELECT user_id FROM sessions WHERE session_token = $1 AND expires_at > NOW()"
+
+	var userID int
+	err := repo.db.QueryRow(query, sessionToken).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrNotLoggedIn
+		}
+		return 0, fmt.Errorf("failed to query session: %w", err)
+	}
+
+	return userID, nil
+}
+
+func (repo *UserRepository) GetByEmail(email string) (User, error) {
+	query := "SELECT id, username, email, password_hash FROM users WHERE email = $1"
+
+	var user User
+	err := repo.db.QueryRow(query, email).Scan(&user.ID, &user.Username, &user.Email, &user.HashedPassword)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return User{}, ErrInvalidCredentials
+		}
+		return User{}, fmt.Errorf("failed to query user: %w", err)
+	}
+
+	return user, nil
+}
+
+// CreateSession binds the session token to the user, logging in again moves the session to the new user
+func (repo *UserRepository) CreateSession(userID int, sessionToken string, expiresAt time.Time) error {
+	query := `INSERT INTO sessions (user_id, session_token, expires_at) VALUES ($1, $2, $3)
+		ON CONFLICT (session_token) DO UPDATE SET user_id = EXCLUDED.user_id, expires_at = EXCLUDED.expires_at`
+
+	_, err := repo.db.Exec(query, userID, sessionToken, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert session: %w", err)
+	}
+
+	return nil
+}
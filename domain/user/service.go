@@ -1,6 +1,22 @@
 package user
 
-import "database/sql"
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrNotLoggedIn is returned when a session is not bound to a user
+	ErrNotLoggedIn = errors.New("session is not logged in")
+	// ErrInvalidCredentials is returned by Login for an unknown email or a wrong password
+	ErrInvalidCredentials = errors.New("invalid email or password")
+)
+
+// loginTTL is how long a session stays bound to the user after logging in
+var loginTTL = 24 * time.Hour
 
 type UserService struct {
 	repo *UserRepository
@@ -15,3 +31,26 @@ func NewUserService(db *sql.DB) *UserService {
 func (s *UserService) CreateUser(user *User) error {
 	return s.repo.Create(user)
 }
+
+// Login checks the password and binds the session to the user, so the holds of the session can be paid.
+// sessionToken is a session ID issued for this login, never the one the client came with.
+func (s *UserService) Login(email, password, sessionToken string) (int, error) {
+	user, err := s.repo.GetByEmail(email)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword(user.HashedPassword, []byte(password)); err != nil {
+		return 0, ErrInvalidCredentials
+	}
+
+	if err := s.repo.CreateSession(user.ID, sessionToken, time.Now().Add(loginTTL)); err != nil {
+		return 0, err
+	}
+
+	return user.ID, nil
+}
+
+func (s *UserService) GetIDBySessionToken(sessionToken string) (int, error) {
+	return s.repo.GetIDBySessionToken(sessionToken)
+}
@@ -8,15 +8,19 @@ type Venue struct {
 	Sections []Section `json:"sections,omitempty" validate:"dive"` // Validate each section
 }
 
+// Rank orders the sections of a venue, and the rows of a section, by view: 1 is the closest to the stage.
+// Left out, it is the position in the list.
 type Section struct {
 	ID   int    `db:"id" json:"id,omitempty"`
 	Name string `db:"name" json:"name" validate:"required,min=3,max=100"`
+	Rank int    `db:"rank" json:"rank,omitempty" validate:"min=0"`
 	Rows []Row  `json:"rows" validate:"dive"`
 }
 
 type Row struct {
 	ID    int    `db:"id" json:"id,omitempty"`
 	Name  string `db:"name" json:"name" validate:"required,min=1,max=50"`
+	Rank  int    `db:"rank" json:"rank,omitempty" validate:"min=0"`
 	Seats []Seat `json:"seats" validate:"dive"`
 }
 
@@ -40,6 +44,21 @@ type SeatPriceBlock struct {
 	Price           int `db:"price"`
 }
 
+// ViewRanks are the ranks of the sections and rows of the venue of an event, see Section
+type ViewRanks struct {
+	Sections map[int]int // section ID -> rank
+	Rows     map[int]int // row ID -> rank
+}
+
+// SeatLocation places a seat of an event in the venue
+type SeatLocation struct {
+	SeatID     int `db:"seat_id"`
+	SeatNumber int `db:"seat_number"`
+	RowID      int `db:"row_id"`
+	SectionID  int `db:"section_id"`
+	Price      int `db:"price"`
+}
+
 type RowCondition struct {
 	RowID          int             `db:"row_id"`
 	RowName        string          `db:"row_name"`
@@ -33,20 +33,20 @@ func (repo *VenueRepository) Create(venue *Venue) error {
 	}
 
 	// Insert sections
-	for _, section := range venue.Sections {
-		sectionQuery := "INSERT INTO sections (name, venue_id) VALUES ($1, $2) RETURNING id"
+	for i, section := range venue.Sections {
+		sectionQuery := "INSERT INTO sections (name, venue_id, rank) VALUES ($1, $2, $3) RETURNING id"
 		var sectionID int
-		err = tx.QueryRow(sectionQuery, section.Name, venueID).Scan(&sectionID)
+		err = tx.QueryRow(sectionQuery, section.Name, venueID, getRank(section.Rank, i)).Scan(&sectionID)
 		if err != nil {
 			tx.Rollback()
 			return fmt.Errorf("failed to insert venue: %w", err)
 		}
 
 		// Insert rows
-		for _, row := range section.Rows {
-			rowQuery := "INSERT INTO rows (name, section_id) VALUES ($1, $2) RETURNING id"
+		for j, row := range section.Rows {
+			rowQuery := "INSERT INTO rows (name, section_id, rank) VALUES ($1, $2, $3) RETURNING id"
 			var rowID int
-			err = tx.QueryRow(rowQuery, row.Name, sectionID).Scan(&rowID)
+			err = tx.QueryRow(rowQuery, row.Name, sectionID, getRank(row.Rank, j)).Scan(&rowID)
 			if err != nil {
 				tx.Rollback()
 				return fmt.Errorf("failed to insert rows: %w", err)
@@ -68,6 +68,14 @@ func (repo *VenueRepository) Create(venue *Venue) error {
 	return tx.Commit()
 }
 
+// getRank defaults a rank left out to the position in the list
+func getRank(rank, index int) int {
+	if rank == 0 {
+		return index + 1
+	}
+	return rank
+}
+
 func (repo *VenueRepository) Exist(id int) (bool, error) {
 	query := "SELECT COUNT(*) FROM venues WHERE id = $1"
 
@@ -208,6 +216,33 @@ func (repo *VenueRepository) GetSeatPriceBlocks(eventID, sectionID int) ([]SeatP
 	return seatPriceBlocks, nil
 }
 
+func (repo *VenueRepository) GetViewRanks(eventID int) (ViewRanks, error) {
+	query := `
+		SELECT sections.id, sections.rank, rows.id, rows.rank
+		FROM events
+		JOIN sections ON sections.venue_id = events.venue_id
+		JOIN rows ON rows.section_id = sections.id
+		WHERE events.id = $1
+	`
+	rows, err := repo.db.Query(query, eventID)
+	if err != nil {
+		return ViewRanks{}, fmt.Errorf("failed to query view ranks: %w", err)
+	}
+	defer rows.Close()
+
+	viewRanks := ViewRanks{Sections: make(map[int]int), Rows: make(map[int]int)}
+	for rows.Next() {
+		var sectionID, sectionRank, rowID, rowRank int
+		if err := rows.Scan(&sectionID, &sectionRank, &rowID, &rowRank); err != nil {
+			return ViewRanks{}, fmt.Errorf("failed to scan row: %w", err)
+		}
+		viewRanks.Sections[sectionID] = sectionRank
+		viewRanks.Rows[rowID] = rowRank
+	}
+
+	return viewRanks, rows.Err()
+}
+
 func (repo *VenueRepository) GetSectionNameByID(id int) (string, error) {
 	query := `SELECT name FROM sections WHERE sections.id = $1`
 
@@ -290,3 +325,33 @@ func (repo *VenueRepository) GetRowConditionByID(rowID, eventID int) (RowConditi
 
 	return rowCondition, nil
 }
+
+// GetEventSeatLocations returns the seats on sale for the event among seatIDs, the others are left out
+func (repo *VenueRepository) GetEventSeatLocations(eventID int, seatIDs []int) ([]SeatLocation, error) {
+	query := `
+		SELECT seats.id, seats.seat_number, rows.id, rows.section_id, event_seat.price
+		FROM seats
+		JOIN rows ON rows.id = seats.row_id
+		JOIN event_seat ON event_seat.seat_id = seats.id
+		WHERE event_seat.event_id = $1
+		AND seats.id = ANY($2)
+		ORDER BY rows.section_id, rows.id, seats.seat_number
+	`
+	rows, err := repo.db.Query(query, eventID, pq.Array(seatIDs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query seat locations: %w", err)
+	}
+	defer rows.Close()
+
+	seatLocations := []SeatLocation{}
+	for rows.Next() {
+		var seatLocation SeatLocation
+		if err := rows.Scan(&seatLocation.SeatID, &seatLocation.SeatNumber, &seatLocation.RowID,
+			&seatLocation.SectionID, &seatLocation.Price); err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+		seatLocations = append(seatLocations, seatLocation)
+	}
+
+	return seatLocations, rows.Err()
+}
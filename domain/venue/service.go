@@ -32,6 +32,10 @@ func (s *VenueService) GetSeatPriceBlocks(eventID, sectionID int) ([]SeatPriceBl
 	return s.repo.GetSeatPriceBlocks(eventID, sectionID)
 }
 
+func (s *VenueService) GetViewRanks(eventID int) (ViewRanks, error) {
+	return s.repo.GetViewRanks(eventID)
+}
+
 func (s *VenueService) GetSectionNameByID(id int) (string, error) {
 	return s.repo.GetSectionNameByID(id)
 }
@@ -43,3 +47,7 @@ func (s *VenueService) GetRowNameByID(id int) (string, error) {
 func (s *VenueService) GetRowConditionByID(rowID, eventID int) (RowCondition, error) {
 	return s.repo.GetRowConditionByID(rowID, eventID)
 }
+
+func (s *VenueService) GetEventSeatLocations(eventID int, seatIDs []int) ([]SeatLocation, error) {
+	return s.repo.GetEventSeatLocations(eventID, seatIDs)
+}
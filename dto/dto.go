@@ -12,6 +12,11 @@ type PostUser struct {
 	Password string `json:"password" validate:"min=8,max=20"`
 }
 
+type PostLogin struct {
+	Email    string `json:"email" validate:"email,required"`
+	Password string `json:"password" validate:"required"`
+}
+
 type GetUser struct {
 	ID       int    `json:"id"`
 	Username string `json:"username"`
@@ -24,10 +29,13 @@ type SetSeatsPriceDTO struct {
 }
 
 type ReservationDTO struct { //EventID is path variable
-	SectionID int `json:"section_id" validate:"required"`
-	RowID     int `json:"row_id" validate:"required"`
+	// Without section and row, the best available seats at the price are reserved in any section
+	SectionID int `json:"section_id" validate:"required_with=RowID"`
+	RowID     int `json:"row_id" validate:"required_with=SectionID"`
 	Price     int `json:"price" validate:"required,min=0"`
-	Length    int `json:"length" validate:"required,min=1,max=6"`
+	Length    int `json:"length" validate:"required_without=SeatIDs,omitempty,min=1,max=6"`
+	// SeatIDs reserves these exact seats, section, row and length are ignored
+	SeatIDs []int `json:"seat_ids,omitempty" validate:"omitempty,max=6,unique,dive,min=1"`
 }
 
 type PostEventDTO struct {
@@ -52,16 +60,35 @@ func RegisterCustomValidations(v *validator.Validate) {
 	v.RegisterValidation("gtfield", validateEventTimes)
 }
 
+// Modes of a ReservationMsg
+const (
+	ReservationModeRow           = ""               // the first seats available in the row
+	ReservationModeBestAvailable = "best_available" // seats chosen by the seat picker, section and row are not set
+	ReservationModeSeats         = "seats"          // the seats of Seats, section and row are not set
+)
+
+// SeatRef is a seat of a ReservationMsg in the seats mode
+type SeatRef struct {
+	SeatID     int `json:"seat_id"`
+	SectionID  int `json:"section_id"`
+	RowID      int `json:"row_id"`
+	SeatNumber int `json:"seat_number"`
+}
+
 type ReservationMsg struct {
-	EventID   int    `json:"event_id"`
-	SectionID int    `json:"section_id"`
-	RowID     int    `json:"row_id"`
-	Price     int    `json:"price"`
-	Length    int    `json:"length"`
-	SessionID string `json:"session_id"` //track user
+	RequestID string    `json:"request_id"` // correlates the async result with the request
+	Mode      string    `json:"mode,omitempty"`
+	EventID   int       `json:"event_id"`
+	SectionID int       `json:"section_id"`
+	RowID     int       `json:"row_id"`
+	Price     int       `json:"price"`
+	Length    int       `json:"length"`
+	Seats     []SeatRef `json:"seats,omitempty"`
+	SessionID string    `json:"session_id"` //track user
 }
 
 type BroadcastMsgs struct {
+	Seq      int64          `json:"seq,omitempty"` // sequence number within the event, see SubscribePayload.LastSeq
 	Messages []BroadcastMsg `json:"messages"`
 }
 
@@ -74,10 +101,54 @@ type BroadcastMsg struct {
 }
 
 type NotificationMsg struct {
+	RequestID       string `json:"request_id"`
+	HoldID          string `json:"hold_id"`
+	StartSeatNumber int    `json:"start_seat_number"`
+	EventID         int    `json:"event_id"`
+	SectionID       int    `json:"section_id"`
+	RowID           int    `json:"row_id"`
+	Price           int    `json:"price"`
+	Length          int    `json:"length"`
+	SessionID       string `json:"session_id"` //to whom
+}
+
+type PaymentMsg struct {
+	EventID   int    `json:"event_id"`
+	HoldID    string `json:"hold_id"` // field key in session:<id>:reservations
+	UserID    int    `json:"user_id"`
+	SessionID string `json:"session_id"`
+}
+
+// Reason codes of a FailureMsg
+const (
+	ReasonRowNotFound     = "row_not_found"
+	ReasonNotEnoughSeats  = "not_enough_seats"
+	ReasonSeatUnavailable = "seat_unavailable"
+	ReasonPriceMismatch   = "price_mismatch"
+	ReasonBusy            = "busy" // gave up retrying, the client may try again
+	ReasonInternalError   = "internal_error"
+	// Payments
+	ReasonPaymentDeclined   = "payment_declined"
+	ReasonHoldNotFound      = "hold_not_found" // expired, or already being paid
+	ReasonSeatAlreadyBooked = "seat_already_booked"
+)
+
+type FailureMsg struct {
+	RequestID string `json:"request_id"`
 	EventID   int    `json:"event_id"`
 	SectionID int    `json:"section_id"`
 	RowID     int    `json:"row_id"`
-	Price     int    `json:"price"`
-	Length    int    `json:"length"`
+	Reason    string `json:"reason"`
+	Message   string `json:"message"`
 	SessionID string `json:"session_id"` //to whom
 }
+
+// PaymentResultMsg is the outcome of a queued payment of a hold
+type PaymentResultMsg struct {
+	EventID   int    `json:"event_id"`
+	HoldID    string `json:"hold_id"`
+	Amount    int    `json:"amount,omitempty"`  // charged, on success
+	Reason    string `json:"reason,omitempty"`  // on failure
+	Message   string `json:"message,omitempty"` // on failure
+	SessionID string `json:"session_id"`        //to whom
+}
@@ -0,0 +1,99 @@
+package dto
+
+import "encoding/json"
+
+// WSProtocolVersion is bumped on breaking changes of the WebSocket messages
+const WSProtocolVersion = 1
+
+// Envelope wraps every WebSocket message, in both directions
+type Envelope struct {
+	Version int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"` // set by the client on commands, echoed in the reply
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// Client -> server message types
+const (
+	TypePing        = "ping"         // no payload
+	TypeSubscribe   = "subscribe"    // SubscribePayload
+	TypeUnsubscribe = "unsubscribe"  // UnsubscribePayload
+	TypeHoldRefresh = "hold_refresh" // HoldPayload
+	TypeCancel      = "cancel"       // HoldPayload
+)
+
+// Server -> client message types
+const (
+	TypePong                 = "pong"                  // no payload
+	TypeAck                  = "ack"                   // payload depends on the command
+	TypeError                = "error"                 // ErrorPayload
+	TypeAvailability         = "availability"          // BroadcastMsgs
+	TypeReservationSucceeded = "reservation_succeeded" // NotificationMsg
+	TypeReservationFailed    = "reservation_failed"    // FailureMsg
+	TypeResyncRequired       = "resync_required"       // ResyncPayload
+	TypePaymentSucceeded     = "payment_succeeded"     // PaymentResultMsg
+	TypePaymentFailed        = "payment_failed"        // PaymentResultMsg
+)
+
+// Codes of an ErrorPayload
+const (
+	ErrorBadRequest         = "bad_request"
+	ErrorUnsupportedVersion = "unsupported_version"
+	ErrorUnknownType        = "unknown_type"
+	ErrorHoldNotFound       = "hold_not_found"
+	ErrorInternal           = "internal_error"
+)
+
+type SubscribePayload struct {
+	EventID  int   `json:"event_id" validate:"required,min=1"`
+	Sections []int `json:"sections,omitempty"` // section IDs, empty means every section
+	// LastSeq is the seq of the last availability message received for the event,
+	// set when resubscribing after a reconnect to replay the messages missed since.
+	// Replayed and live messages may interleave, messages with a seq already seen are to be skipped.
+	LastSeq int64 `json:"last_seq,omitempty" validate:"min=0"`
+}
+
+// ResyncPayload tells the client the missed messages are gone: reload the availability
+// of the event and continue from Seq
+type ResyncPayload struct {
+	EventID int   `json:"event_id"`
+	Seq     int64 `json:"seq"`
+}
+
+type UnsubscribePayload struct {
+	EventID int `json:"event_id" validate:"required,min=1"`
+}
+
+type HoldPayload struct {
+	EventID int    `json:"event_id" validate:"required,min=1"`
+	HoldID  string `json:"hold_id" validate:"required"`
+}
+
+type HoldRefreshedPayload struct {
+	HoldID    string `json:"hold_id"`
+	ExpiresIn int    `json:"expires_in"` // seconds
+}
+
+type ErrorPayload struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// NewEnvelope marshals payload into an envelope of the current protocol version
+func NewEnvelope(msgType, id string, payload interface{}) ([]byte, error) {
+	envelope := Envelope{
+		Version: WSProtocolVersion,
+		Type:    msgType,
+		ID:      id,
+	}
+
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		envelope.Payload = data
+	}
+
+	return json.Marshal(envelope)
+}
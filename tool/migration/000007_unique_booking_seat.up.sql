@@ -0,0 +1,2 @@
+ALTER TABLE bookings
+ADD CONSTRAINT unique_booking_event_seat UNIQUE (event_seat_id);
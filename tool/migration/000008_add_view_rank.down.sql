@@ -0,0 +1,5 @@
+ALTER TABLE rows
+DROP COLUMN IF EXISTS rank;
+
+ALTER TABLE sections
+DROP COLUMN IF EXISTS rank;
@@ -0,0 +1,17 @@
+-- Rank of the view from the stage, 1 is the closest. Best view sorts sections, then rows, by rank.
+ALTER TABLE sections
+ADD COLUMN IF NOT EXISTS rank int NOT NULL DEFAULT 0;
+
+ALTER TABLE rows
+ADD COLUMN IF NOT EXISTS rank int NOT NULL DEFAULT 0;
+
+-- Venues were described from the stage backwards, rank the existing sections and rows by ID
+UPDATE sections
+SET rank = ranked.rank
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY venue_id ORDER BY id) AS rank FROM sections) ranked
+WHERE sections.id = ranked.id;
+
+UPDATE rows
+SET rank = ranked.rank
+FROM (SELECT id, ROW_NUMBER() OVER (PARTITION BY section_id ORDER BY id) AS rank FROM rows) ranked
+WHERE rows.id = ranked.id;
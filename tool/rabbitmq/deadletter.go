@@ -0,0 +1,148 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"log"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+// Headers recorded on dead-lettered messages
+const (
+	headerFailureReason = "x-failure-reason"
+	headerFailedAt      = "x-failed-at"
+	headerOriginalQueue = "x-original-queue"
+	headerReplayCount   = "x-replay-count"
+)
+
+type DeadLetter struct {
+	Body          string `json:"body"`
+	FailureReason string `json:"failure_reason"`
+	FailedAt      string `json:"failed_at"`
+	OriginalQueue string `json:"original_queue"`
+	ReplayCount   int32  `json:"replay_count"`
+}
+
+func getDeadLetterExchange(queueName string) string {
+	return queueName + ".dlx"
+}
+
+func getDeadLetterQueue(queueName string) string {
+	return queueName + ".dead"
+}
+
+// PeekDeadLetters returns up to limit dead-lettered messages of an action without removing them
+func (r *RabbitMQ) PeekDeadLetters(action string, limit int) ([]DeadLetter, error) {
+	_, queueName, err := r.getChannelAndQueue(action)
+	if err != nil {
+		return nil, err
+	}
+
+	// A dedicated channel, closing it puts the unacked messages back in the queue
+	channel, err := r.conn.Channel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer channel.Close()
+
+	deadLetters := []DeadLetter{}
+	for len(deadLetters) < limit {
+		msg, ok, err := channel.Get(getDeadLetterQueue(queueName), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get dead letter: %w", err)
+		}
+		if !ok { // queue is empty
+			break
+		}
+		deadLetters = append(deadLetters, toDeadLetter(msg))
+	}
+
+	return deadLetters, nil
+}
+
+// ReplayDeadLetters moves up to limit dead-lettered messages of an action back to its queue
+func (r *RabbitMQ) ReplayDeadLetters(action string, limit int) (int, error) {
+	_, queueName, err := r.getChannelAndQueue(action)
+	if err != nil {
+		return 0, err
+	}
+
+	channel, err := r.conn.Channel()
+	if err != nil {
+		return 0, fmt.Errorf("failed to open channel: %w", err)
+	}
+	defer channel.Close()
+
+	// A dead letter is acked only once the broker confirms it is back in the queue
+	if err := channel.Confirm(false); err != nil {
+		return 0, fmt.Errorf("failed to put channel in confirm mode: %w", err)
+	}
+
+	replayed := 0
+	for replayed < limit {
+		msg, ok, err := channel.Get(getDeadLetterQueue(queueName), false)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to get dead letter: %w", err)
+		}
+		if !ok {
+			break
+		}
+
+		headers := amqp.Table{}
+		for key, value := range msg.Headers {
+			headers[key] = value
+		}
+		headers[headerReplayCount] = toDeadLetter(msg).ReplayCount + 1
+
+		err = publishConfirmed(channel, "", queueName, amqp.Publishing{
+			ContentType:  msg.ContentType,
+			DeliveryMode: amqp.Persistent,
+			Headers:      headers,
+			Body:         msg.Body,
+		})
+		if err != nil {
+			if err := msg.Nack(false, true); err != nil { // keep it in the dead-letter queue
+				log.Printf("Failed to NACK dead letter: %v", err)
+			}
+			return replayed, fmt.Errorf("failed to replay dead letter: %w", err)
+		}
+
+		if err := msg.Ack(false); err != nil {
+			return replayed, fmt.Errorf("failed to ack replayed dead letter: %w", err)
+		}
+		replayed++
+	}
+
+	log.Printf("replayed %d dead letters to %s", replayed, queueName)
+
+	return replayed, nil
+}
+
+func toDeadLetter(msg amqp.Delivery) DeadLetter {
+	deadLetter := DeadLetter{Body: string(msg.Body)}
+
+	if reason, ok := msg.Headers[headerFailureReason].(string); ok {
+		deadLetter.FailureReason = reason
+	}
+	if failedAt, ok := msg.Headers[headerFailedAt].(string); ok {
+		deadLetter.FailedAt = failedAt
+	}
+	if originalQueue, ok := msg.Headers[headerOriginalQueue].(string); ok {
+		deadLetter.OriginalQueue = originalQueue
+	}
+	if replayCount, ok := msg.Headers[headerReplayCount].(int32); ok {
+		deadLetter.ReplayCount = replayCount
+	}
+
+	// Nacked messages routed by the broker only carry x-death
+	if deadLetter.FailureReason == "" {
+		if deaths, ok := msg.Headers["x-death"].([]interface{}); ok && len(deaths) > 0 {
+			if death, ok := deaths[0].(amqp.Table); ok {
+				deadLetter.FailureReason, _ = death["reason"].(string)
+				deadLetter.OriginalQueue, _ = death["queue"].(string)
+			}
+		}
+	}
+
+	return deadLetter
+}
@@ -1,9 +1,11 @@
 package rabbitmq
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
+	"time"
 
 	"ticket-booking-backend/tool/util"
 
@@ -11,19 +13,35 @@ import (
 )
 
 type RabbitMQ struct {
-	conn           *amqp.Connection //TCP connection
-	bookingChannel *amqp.Channel    //virtual connection, used to send and receive messages.
-	paymentChannel *amqp.Channel
+	conn              *amqp.Connection //TCP connection
+	bookingChannel    *amqp.Channel    //virtual connection, used to send and receive messages.
+	paymentChannel    *amqp.Channel
+	deadLetterChannel *amqp.Channel // in confirm mode, a failed message is acked only once the broker has its copy
+	bookingQueueName  string
+	paymentQueueName  string
+	// Queues of the releases before dead-lettering, consumed until they are deleted. Empty when gone.
+	legacyBookingQueueName string
+	legacyPaymentQueueName string
 }
 
 // queues are buffers "inside" channel
 
 var (
 	defaultRabbitMQURL      = os.Getenv("RABBIT_MQ_URL")
-	defaultBookingQueueName = os.Getenv("BOOKING_QUEUE_NAME")
-	defaultPaymentQueueName = os.Getenv("PAYMENT_QUEUE_NAME")
+	defaultBookingQueueName = "booking-queue.v2"
+	defaultPaymentQueueName = "payment-queue.v2"
+
+	// The queues used to be declared without dead-letter arguments, and redeclaring an existing queue
+	// with other arguments fails with PRECONDITION_FAILED. The queues were renamed instead:
+	// the old ones are still consumed, so the messages published by instances not upgraded yet are processed.
+	// Delete them once every instance is upgraded and they are empty.
+	legacyBookingQueueName = "booking-queue"
+	legacyPaymentQueueName = "payment-queue"
 )
 
+// confirmTimeout bounds the wait for the broker to confirm a dead-lettered or replayed message
+var confirmTimeout = 5 * time.Second
+
 func InitRabbitMQ() *RabbitMQ {
 	rabbitMQURL := util.GetEnvOrDefault("RABBIT_MQ_URL", defaultRabbitMQURL)
 	conn, err := amqp.Dial(rabbitMQURL)
@@ -35,6 +53,10 @@ func InitRabbitMQ() *RabbitMQ {
 	// Initialize channels and declare queues
 	bookingChannel := createChannel(conn, "booking")
 	paymentChannel := createChannel(conn, "payment")
+	deadLetterChannel := createChannel(conn, "dead-letter")
+	if err := deadLetterChannel.Confirm(false); err != nil {
+		log.Fatalf("Failed to put dead-letter channel in confirm mode: %v", err)
+	}
 
 	// Declare queues
 	bookingQueueName := util.GetEnvOrDefault("BOOKING_QUEUE_NAME", defaultBookingQueueName)
@@ -44,9 +66,14 @@ func InitRabbitMQ() *RabbitMQ {
 	declareQueue(paymentChannel, paymentQueueName)
 
 	return &RabbitMQ{
-		conn:           conn,
-		bookingChannel: bookingChannel,
-		paymentChannel: paymentChannel,
+		conn:                   conn,
+		bookingChannel:         bookingChannel,
+		paymentChannel:         paymentChannel,
+		deadLetterChannel:      deadLetterChannel,
+		bookingQueueName:       bookingQueueName,
+		paymentQueueName:       paymentQueueName,
+		legacyBookingQueueName: findLegacyQueue(conn, legacyBookingQueueName, bookingQueueName),
+		legacyPaymentQueueName: findLegacyQueue(conn, legacyPaymentQueueName, paymentQueueName),
 	}
 }
 
@@ -61,6 +88,11 @@ func (r *RabbitMQ) Close() error {
 			return err
 		}
 	}
+	if r.deadLetterChannel != nil {
+		if err := r.deadLetterChannel.Close(); err != nil {
+			return err
+		}
+	}
 
 	// Close the connection if it exists
 	if r.conn != nil {
@@ -73,22 +105,12 @@ func (r *RabbitMQ) Close() error {
 }
 
 func (r *RabbitMQ) PublishMessage(action string, body []byte) error {
-	var channel *amqp.Channel
-	var queueName string
-
-	switch action {
-	case "book":
-		channel = r.bookingChannel
-		queueName = "booking-queue"
-	case "pay":
-		channel = r.paymentChannel
-		queueName = "payment-queue"
-	default:
-		log.Printf(`Unknown action : %s, should be either "book" or "pay" `, action)
-		return fmt.Errorf("unknown action name in publish message : %s", action)
+	channel, queueName, err := r.getChannelAndQueue(action)
+	if err != nil {
+		return err
 	}
 
-	err := channel.Publish(
+	err = channel.Publish(
 		"",        // exchange
 		queueName, // routing key
 		false,     // mandatory
@@ -108,22 +130,24 @@ func (r *RabbitMQ) PublishMessage(action string, body []byte) error {
 }
 
 func (r *RabbitMQ) ConsumeMessages(action string, handler func([]byte) error) error {
-	var channel *amqp.Channel
-	var queueName string
+	channel, queueName, err := r.getChannelAndQueue(action)
+	if err != nil {
+		return err
+	}
 
-	switch action {
-	case "book":
-		channel = r.bookingChannel
-		queueName = "booking-queue"
-	case "pay":
-		channel = r.paymentChannel
-		queueName = "payment-queue"
+	if err := r.consume(channel, queueName, queueName, handler); err != nil {
+		return err
+	}
 
-	default:
-		log.Printf(`Unknown action : %s, should be either "book" or "pay" `, action)
-		return fmt.Errorf("unknown action name in publish message : %s", action)
+	if legacyQueueName := r.getLegacyQueue(action); legacyQueueName != "" {
+		log.Printf("Draining legacy queue %s into the handler of %s", legacyQueueName, queueName)
+		return r.consume(channel, legacyQueueName, queueName, handler)
 	}
+	return nil
+}
 
+// consume runs handler on the messages of queueName, the failed ones are dead-lettered as messages of deadLetterQueueName
+func (r *RabbitMQ) consume(channel *amqp.Channel, queueName, deadLetterQueueName string, handler func([]byte) error) error {
 	msgs, err := channel.Consume( //set the consumer of the channel and consume the messages in msgs
 		queueName, // queue
 		"",        // consumer
@@ -141,9 +165,7 @@ func (r *RabbitMQ) ConsumeMessages(action string, handler func([]byte) error) er
 		for msg := range msgs {
 			if err := handler(msg.Body); err != nil {
 				log.Printf("Failed to process message: %v", err)
-				if err := msg.Nack(false, false); err != nil { // second param in msg.Nack() means requeue
-					log.Printf("Failed to NACK message: %v", err)
-				}
+				r.deadLetter(deadLetterQueueName, msg, err)
 			} else {
 				if err := msg.Ack(false); err != nil {
 					log.Printf("Failed to ACK message: %v", err)
@@ -155,6 +177,111 @@ func (r *RabbitMQ) ConsumeMessages(action string, handler func([]byte) error) er
 	return nil
 }
 
+// deadLetter moves a failed message to the dead-letter queue along with the failure reason.
+// The message is acked only once the broker confirms the copy, otherwise it is requeued.
+func (r *RabbitMQ) deadLetter(queueName string, msg amqp.Delivery, handlerErr error) {
+	headers := amqp.Table{}
+	for key, value := range msg.Headers {
+		headers[key] = value
+	}
+	headers[headerFailureReason] = handlerErr.Error()
+	headers[headerFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	headers[headerOriginalQueue] = queueName
+
+	err := publishConfirmed(r.deadLetterChannel, getDeadLetterExchange(queueName), queueName, amqp.Publishing{
+		ContentType:  msg.ContentType,
+		DeliveryMode: amqp.Persistent,
+		Headers:      headers,
+		Body:         msg.Body,
+	})
+	if err != nil {
+		log.Printf("Failed to publish message to dead-letter queue of %s: %v", queueName, err)
+		if err := msg.Nack(false, true); err != nil { // second param in msg.Nack() means requeue
+			log.Printf("Failed to NACK message: %v", err)
+		}
+		return
+	}
+
+	if err := msg.Ack(false); err != nil {
+		log.Printf("Failed to ACK message: %v", err)
+	}
+}
+
+func (r *RabbitMQ) getChannelAndQueue(action string) (*amqp.Channel, string, error) {
+	switch action {
+	case "book":
+		return r.bookingChannel, r.bookingQueueName, nil
+	case "pay":
+		return r.paymentChannel, r.paymentQueueName, nil
+	default:
+		log.Printf(`Unknown action : %s, should be either "book" or "pay" `, action)
+		return nil, "", fmt.Errorf("unknown action name : %s", action)
+	}
+}
+
+func (r *RabbitMQ) getLegacyQueue(action string) string {
+	switch action {
+	case "book":
+		return r.legacyBookingQueueName
+	case "pay":
+		return r.legacyPaymentQueueName
+	default:
+		return ""
+	}
+}
+
+// findLegacyQueue returns legacyQueueName if the queue still exists on the broker, an empty string otherwise
+func findLegacyQueue(conn *amqp.Connection, legacyQueueName, queueName string) string {
+	if legacyQueueName == queueName {
+		return ""
+	}
+
+	// A missing queue closes the channel, check on a channel of its own
+	channel := createChannel(conn, "legacy queue check")
+	defer channel.Close()
+
+	queue, err := channel.QueueDeclarePassive(
+		legacyQueueName, // name
+		true,            // durable
+		false,           // delete when unused
+		false,           // exclusive
+		false,           // no-wait
+		nil,             // arguments
+	)
+	if err != nil {
+		return ""
+	}
+
+	log.Printf("Legacy queue %s still holds %d messages, delete it once empty and no instance publishes to it", legacyQueueName, queue.Messages)
+	return legacyQueueName
+}
+
+// publishConfirmed publishes on a channel in confirm mode and waits until the broker has taken the message
+func publishConfirmed(channel *amqp.Channel, exchange, routingKey string, publishing amqp.Publishing) error {
+	ctx, cancel := context.WithTimeout(context.Background(), confirmTimeout)
+	defer cancel()
+
+	confirmation, err := channel.PublishWithDeferredConfirmWithContext(ctx,
+		exchange,   // exchange
+		routingKey, // routing key
+		false,      // mandatory
+		false,      // immediate
+		publishing,
+	)
+	if err != nil {
+		return err
+	}
+
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to wait for publish confirmation: %w", err)
+	}
+	if !acked {
+		return fmt.Errorf("broker refused the message")
+	}
+	return nil
+}
+
 func createChannel(conn *amqp.Connection, channelName string) *amqp.Channel {
 	channel, err := conn.Channel()
 	if err != nil {
@@ -163,15 +290,59 @@ func createChannel(conn *amqp.Connection, channelName string) *amqp.Channel {
 	return channel
 }
 
-// declareQueue declares a queue with the given name on the specified channel
+// declareQueue declares a queue with the given name on the specified channel,
+// together with its dead-letter exchange and queue.
+// The arguments of an existing queue can't be changed, see legacyBookingQueueName.
 func declareQueue(channel *amqp.Channel, queueName string) {
-	_, err := channel.QueueDeclare(
+	deadLetterExchange := getDeadLetterExchange(queueName)
+	deadLetterQueue := getDeadLetterQueue(queueName)
+
+	err := channel.ExchangeDeclare(
+		deadLetterExchange, // name
+		"direct",           // kind
+		true,               // durable
+		false,              // auto-deleted
+		false,              // internal
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		log.Fatalf("Failed to declare %s exchange: %v", deadLetterExchange, err)
+	}
+
+	_, err = channel.QueueDeclare(
+		deadLetterQueue, // name
+		true,            // durable
+		false,           // delete when unused
+		false,           // exclusive
+		false,           // no-wait
+		nil,             // arguments
+	)
+	if err != nil {
+		log.Fatalf("Failed to declare %s queue: %v", deadLetterQueue, err)
+	}
+
+	err = channel.QueueBind(
+		deadLetterQueue,    // queue name
+		queueName,          // routing key
+		deadLetterExchange, // exchange
+		false,              // no-wait
+		nil,                // arguments
+	)
+	if err != nil {
+		log.Fatalf("Failed to bind %s queue: %v", deadLetterQueue, err)
+	}
+
+	_, err = channel.QueueDeclare(
 		queueName, // name
 		true,      // durable
 		false,     // delete when unused
 		false,     // exclusive
 		false,     // no-wait
-		nil,       // arguments
+		amqp.Table{ // arguments
+			"x-dead-letter-exchange":    deadLetterExchange,
+			"x-dead-letter-routing-key": queueName,
+		},
 	)
 	if err != nil {
 		log.Fatalf("Failed to declare %s queue: %v", queueName, err)